@@ -0,0 +1,115 @@
+package cord
+
+import (
+	"fmt"
+
+	"github.com/gorilla/websocket"
+)
+
+// A CloseCode is sent by Discord when it closes the gateway connection.
+type CloseCode int
+
+// Close codes sent by the gateway. See CloseCode.Behavior for how each one
+// is handled.
+const (
+	CloseUnknownError         CloseCode = 4000
+	CloseUnknownOpcode        CloseCode = 4001
+	CloseDecodeError          CloseCode = 4002
+	CloseNotAuthenticated     CloseCode = 4003
+	CloseAuthenticationFailed CloseCode = 4004
+	CloseAlreadyAuthenticated CloseCode = 4005
+	CloseInvalidSequence      CloseCode = 4007
+	CloseRateLimited          CloseCode = 4008
+	CloseSessionTimedOut      CloseCode = 4009
+	CloseInvalidShard         CloseCode = 4010
+	CloseShardingRequired     CloseCode = 4011
+	CloseInvalidAPIVersion    CloseCode = 4012
+	CloseInvalidIntents       CloseCode = 4013
+	CloseDisallowedIntents    CloseCode = 4014
+)
+
+var closeCodeNames = map[CloseCode]string{
+	CloseUnknownError:         "unknown error",
+	CloseUnknownOpcode:        "unknown opcode",
+	CloseDecodeError:          "decode error",
+	CloseNotAuthenticated:     "not authenticated",
+	CloseAuthenticationFailed: "authentication failed",
+	CloseAlreadyAuthenticated: "already authenticated",
+	CloseInvalidSequence:      "invalid seq",
+	CloseRateLimited:          "rate limited",
+	CloseSessionTimedOut:      "session timed out",
+	CloseInvalidShard:         "invalid shard",
+	CloseShardingRequired:     "sharding required",
+	CloseInvalidAPIVersion:    "invalid API version",
+	CloseInvalidIntents:       "invalid intent(s)",
+	CloseDisallowedIntents:    "disallowed intent(s)",
+}
+
+// String returns the name Discord documents for the code.
+func (c CloseCode) String() string {
+	if name, ok := closeCodeNames[c]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("close code %d", int(c))
+}
+
+// A CloseBehavior describes what the Websocket does after the connection is
+// closed with a given CloseCode.
+type CloseBehavior uint8
+
+const (
+	// CloseResume reconnects and resumes the previous session.
+	CloseResume CloseBehavior = iota
+	// CloseReidentify reconnects and starts a new session, since the
+	// previous one is no longer valid.
+	CloseReidentify
+	// CloseFatal stops the Websocket. Reconnecting would fail the same way
+	// until the token or options are changed.
+	CloseFatal
+)
+
+// Behavior returns how the Websocket handles the close code:
+//
+//   - 4004 (authentication failed), 4010 (invalid shard), 4011 (sharding
+//     required), 4012 (invalid API version), 4013 (invalid intents) and
+//     4014 (disallowed intents) are fatal.
+//   - 4007 (invalid seq) and 4009 (session timed out) invalidate the
+//     session, as do normal closures (1000 and 1001).
+//   - Everything else is resumed.
+func (c CloseCode) Behavior() CloseBehavior {
+	switch c {
+	case CloseAuthenticationFailed, CloseInvalidShard, CloseShardingRequired,
+		CloseInvalidAPIVersion, CloseInvalidIntents, CloseDisallowedIntents:
+		return CloseFatal
+	case CloseInvalidSequence, CloseSessionTimedOut,
+		websocket.CloseNormalClosure, websocket.CloseGoingAway:
+		return CloseReidentify
+	default:
+		return CloseResume
+	}
+}
+
+// A CloseError is the Cause of the DisruptionError or FatalError sent when
+// the server closes the connection.
+type CloseError struct {
+	Code   CloseCode
+	Reason string
+}
+
+// Error implements error.Error
+func (c CloseError) Error() string {
+	return fmt.Sprintf("cord/websocket: connection closed with %d (%s): %s",
+		int(c.Code), c.Code, c.Reason)
+}
+
+// closeError converts a close frame read from the connection into a
+// CloseError. It returns false if the error is not a close frame.
+func closeError(err error) (CloseError, bool) {
+	wserr, ok := err.(*websocket.CloseError)
+	if !ok {
+		return CloseError{}, false
+	}
+
+	return CloseError{Code: CloseCode(wserr.Code), Reason: wserr.Text}, true
+}
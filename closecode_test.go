@@ -0,0 +1,31 @@
+package cord
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseCodeBehavior(t *testing.T) {
+	tt := []struct {
+		code     CloseCode
+		behavior CloseBehavior
+	}{
+		{CloseUnknownError, CloseResume},
+		{CloseRateLimited, CloseResume},
+		{1006, CloseResume},
+		{1000, CloseReidentify},
+		{CloseInvalidSequence, CloseReidentify},
+		{CloseSessionTimedOut, CloseReidentify},
+		{CloseAuthenticationFailed, CloseFatal},
+		{CloseInvalidShard, CloseFatal},
+		{CloseShardingRequired, CloseFatal},
+		{CloseInvalidAPIVersion, CloseFatal},
+		{CloseInvalidIntents, CloseFatal},
+		{CloseDisallowedIntents, CloseFatal},
+	}
+
+	for _, test := range tt {
+		assert.Equal(t, test.behavior, test.code.Behavior(), test.code.String())
+	}
+}
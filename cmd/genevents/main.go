@@ -26,10 +26,11 @@ var (
 )
 
 {{ range . }}
-// {{ .Struct }} is a handler for {{ .Event }} events.
+// {{ .Struct }} is a handler for {{ .Event }} events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type {{ .Struct }} func(update *model.{{ .Model }})
 
-var _ Handler = {{ .Struct }}(func (m *model.{{ .Model }}) {})
+var _ Decoder = {{ .Struct }}(func (m *model.{{ .Model }}) {})
 
 // Name implements Handler.Name
 func (p {{ .Struct }}) Name() string { return {{ .Struct }}Str }
@@ -44,6 +45,19 @@ func (p {{ .Struct }}) Invoke(b []byte) error {
     p(data)
     return nil
 }
+
+// Decode implements Decoder.Decode
+func (p {{ .Struct }}) Decode(b []byte) (interface{}, error) {
+    data := &model.{{ .Model }}{}
+    if err := data.UnmarshalJSON(b); err != nil {
+        return nil, err
+    }
+
+    return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p {{ .Struct }}) InvokeDecoded(v interface{}) { p(v.(*model.{{ .Model }})) }
 {{ end }}
 `
 )
@@ -0,0 +1,104 @@
+package cord
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// A CompressionMode determines how Discord compresses data sent to us.
+type CompressionMode uint8
+
+const (
+	// CompressPayload sets `compress` in the handshake, so that Discord
+	// compresses large payloads individually. This is the default.
+	CompressPayload CompressionMode = iota
+	// CompressNone disables compression.
+	CompressNone
+	// CompressZlibStream compresses the whole connection as a single zlib
+	// stream. This is usually much smaller than CompressPayload, since
+	// each payload can reference data from those before it.
+	CompressZlibStream
+)
+
+// zlibSuffix ends every payload in a zlib stream; it's the marker written
+// by a sync flush.
+var zlibSuffix = []byte{0x00, 0x00, 0xff, 0xff}
+
+// windowSize is the largest distance a deflate back-reference may have.
+const windowSize = 32 << 10
+
+// zlibStream inflates payloads sent on a zlib-stream connection. Because
+// every payload ends with a sync flush, each one starts on a fresh deflate
+// block and can be inflated on its own given the last window of previous
+// output as a dictionary. This avoids having to keep a reader blocked on
+// the connection between payloads.
+type zlibStream struct {
+	pending []byte
+	window  []byte
+	started bool
+	reader  io.ReadCloser
+}
+
+// Inflate adds the message to the stream and returns the payload it
+// completes, or nil if the payload continues in further messages.
+func (z *zlibStream) Inflate(b []byte) ([]byte, error) {
+	z.pending = append(z.pending, b...)
+	if !bytes.HasSuffix(z.pending, zlibSuffix) {
+		return nil, nil
+	}
+
+	in := z.pending
+	z.pending = nil
+
+	if !z.started {
+		if len(in) < 2 || in[0]&0x0f != 8 || (uint(in[0])<<8|uint(in[1]))%31 != 0 {
+			return nil, fmt.Errorf("cord/websocket: invalid zlib stream header")
+		}
+
+		in = in[2:]
+		z.started = true
+	}
+
+	if z.reader == nil {
+		z.reader = flate.NewReaderDict(bytes.NewReader(in), z.window)
+	} else if err := z.reader.(flate.Resetter).Reset(bytes.NewReader(in), z.window); err != nil {
+		return nil, err
+	}
+
+	// The stream never ends, so the reader always runs out of input after
+	// the flush marker. Anything else is an actual error.
+	out, err := ioutil.ReadAll(z.reader)
+	if err != io.ErrUnexpectedEOF {
+		if err == nil {
+			err = fmt.Errorf("cord/websocket: zlib stream ended unexpectedly")
+		}
+		return nil, err
+	}
+
+	z.window = append(z.window, out...)
+	if len(z.window) > windowSize {
+		z.window = append([]byte(nil), z.window[len(z.window)-windowSize:]...)
+	}
+
+	return out, nil
+}
+
+// isZlib returns whether the bytes start with a zlib header. Uncompressed
+// payloads, either JSON or ETF, never do.
+func isZlib(b []byte) bool {
+	return len(b) >= 2 && b[0]&0x0f == 8 && (uint(b[0])<<8|uint(b[1]))%31 == 0
+}
+
+// inflate decompresses the provided zlib-compressed bytes
+func inflate(b []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,64 @@
+package cord
+
+import (
+	"bytes"
+	"compress/zlib"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// zlibStreamWriter compresses payloads into a single zlib stream, flushing
+// after each one like Discord does.
+type zlibStreamWriter struct {
+	buf bytes.Buffer
+	zw  *zlib.Writer
+}
+
+func newZlibStreamWriter() *zlibStreamWriter {
+	z := &zlibStreamWriter{}
+	z.zw = zlib.NewWriter(&z.buf)
+	return z
+}
+
+func (z *zlibStreamWriter) Payload(b []byte) []byte {
+	z.zw.Write(b)
+	z.zw.Flush()
+	out := append([]byte(nil), z.buf.Bytes()...)
+	z.buf.Reset()
+	return out
+}
+
+func TestZlibStreamInflatesSequentialPayloads(t *testing.T) {
+	w := newZlibStreamWriter()
+	r := &zlibStream{}
+
+	// Large, repetitive payloads make sure that back-references into
+	// previous payloads' output are resolved.
+	for i := 0; i < 5; i++ {
+		payload := []byte(`{"op":0,"d":"` + strings.Repeat("cord", 20000) + `"}`)
+		b, err := r.Inflate(w.Payload(payload))
+		assert.Nil(t, err)
+		assert.Equal(t, payload, b)
+	}
+}
+
+func TestZlibStreamBuffersPartialPayloads(t *testing.T) {
+	w := newZlibStreamWriter()
+	r := &zlibStream{}
+
+	compressed := w.Payload(readyPacket)
+	b, err := r.Inflate(compressed[:len(compressed)/2])
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+
+	b, err = r.Inflate(compressed[len(compressed)/2:])
+	assert.Nil(t, err)
+	assert.Equal(t, readyPacket, b)
+}
+
+func TestZlibStreamRejectsBadHeader(t *testing.T) {
+	_, err := (&zlibStream{}).Inflate([]byte{1, 2, 0, 0, 0xff, 0xff})
+	assert.NotNil(t, err)
+}
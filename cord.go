@@ -1,7 +1,9 @@
 package cord
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/WatchBeam/cord/events"
 )
@@ -10,41 +12,110 @@ import (
 // the socket are safe for concurrent use.
 type Socket interface {
 	// Send dispatches an event down the Discord socket. It returns an error
-	// if there was any issue in sending it.
+	// if there was any issue in sending it. If the socket is disconnected,
+	// Send waits for it to reconnect; it returns ErrClosed, or the
+	// FatalError, if the socket will never reconnect.
 	Send(op Operation, data json.Marshaler) error
 
-	// On attaches a handler to an event.
-	On(h events.Handler)
+	// SendContext is like Send, but gives up and returns the context's
+	// error if it's done before the event is sent.
+	SendContext(ctx context.Context, op Operation, data json.Marshaler) error
 
-	// On attaches a handler that's called once when an event happens.
-	Once(h events.Handler)
+	// TrySend is like Send, but fails rather than waiting: it returns a
+	// NotConnectedError if the socket isn't currently connected, a
+	// RateLimitedError if the command would exceed the rate limits, or a
+	// QueueFullError if the send queue is full, whatever the
+	// QueueOverflow policy.
+	TrySend(op Operation, data json.Marshaler) error
 
-	// Off detaches a previously-attached handler from an event.
+	// On attaches a handler to an event. The returned Subscription
+	// detaches it.
+	On(h events.Handler) Subscription
+
+	// Once attaches a handler that's called once when an event happens.
+	// The returned Subscription detaches it before then.
+	Once(h events.Handler) Subscription
+
+	// Off detaches a previously-attached handler from an event. Handlers
+	// which can't be compared, such as the funcs in the events package,
+	// are never detached by Off.
+	//
+	// Deprecated: use the Subscription returned from On or Once.
 	Off(h events.Handler)
 
+	// Subscribe returns a channel which receives the named event, or every
+	// event if the name is events.AnyStr, with room for size events before
+	// WsOptions.SlowConsumer applies. The channel is closed when cancel is
+	// called or the socket closes. Use events.NewStream to decode them.
+	Subscribe(name string, size int) (ch <-chan events.Event, cancel func())
+
+	// Use adds a middleware which wraps the dispatch of every event, for
+	// instance to log, time or filter events. Middleware is called in the
+	// order it was added.
+	Use(m Middleware)
+
+	// State returns the state of the socket's connection.
+	State() State
+
+	// OnStateChange attaches a handler which is called whenever the state
+	// changes. Handlers are called in the order the changes happened, from
+	// the goroutine which made the change, so they should not block.
+	OnStateChange(h func(old, new State))
+
+	// Budget returns how many more commands may be sent before the
+	// gateway's rate limits are reached. Sends beyond the budget wait in
+	// a queue until the limits reset.
+	Budget() Budget
+
+	// SequenceStats returns how many events were missed or dropped as
+	// duplicates. Each gap is also reported as a SequenceGapError on Errs.
+	SequenceStats() SequenceStats
+
 	// Errs returns a channel of errors which may occur asynchronously
-	// on the websocket.
+	// on the websocket. It's closed after Shutdown or Close.
 	Errs() <-chan error
 
-	// Frees resources associated with the socket.
+	// Shutdown gracefully closes the socket. It waits for queued sends to
+	// be written, closes the connection with a close frame and waits for
+	// the server to acknowledge it. If the context is done first, the
+	// connection is closed immediately and the context's error returned.
+	Shutdown(ctx context.Context) error
+
+	// Close frees resources associated with the socket. Every goroutine
+	// the socket started exits, except for event handlers which are still
+	// running.
 	Close() error
 }
 
 // New creates a connection to the Discord servers. Options may be nil if
 // you want to use the defaults.
 func New(token string, options *WsOptions) Socket {
+	ws := newWebsocket(token, options, newEmitter(), newErrChannel())
+	ws.start()
+
+	return ws
+}
+
+// newWebsocket creates a Websocket which dispatches to the given emitter
+// and sends errors down the given channel, without connecting it.
+func newWebsocket(token string, options *WsOptions, events *emitter, errs *errChannel) *Websocket {
 	if options == nil {
 		options = &WsOptions{}
 	}
 	options.fillDefaults(token)
 
-	ws := &Websocket{
-		opts:   options,
-		events: newEmitter(),
-		errs:   make(chan error),
+	w := &Websocket{
+		opts:       options,
+		events:     events,
+		dispatcher: newDispatcher(events, options.DispatchWorkers, options.DispatchKey),
+		errs:       errs,
+		done:       make(chan struct{}),
+	}
+	if options.SessionStore != nil {
+		w.saver = newSessionSaver(options.SessionStore, func(err error) {
+			go w.sendErr(fmt.Errorf("cord/websocket: error saving session: %s", err))
+		})
 	}
 
-	ws.start()
-
-	return ws
+	return w
 }
@@ -0,0 +1,160 @@
+package cord
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/WatchBeam/cord/events"
+)
+
+// GuildKey is a DispatchKey which orders events by the guild they happened
+// in, falling back to the channel for events outside guilds, such as in
+// direct messages.
+func GuildKey(event string, data []byte) string {
+	packet, ok := readScopedPacket(event, data)
+	if !ok {
+		return ""
+	}
+	if packet.GuildID != "" {
+		return packet.GuildID
+	}
+
+	return packet.ChannelID
+}
+
+// ChannelKey is a DispatchKey which orders events by the channel they
+// happened in, falling back to the guild for events outside channels.
+func ChannelKey(event string, data []byte) string {
+	packet, ok := readScopedPacket(event, data)
+	if !ok {
+		return ""
+	}
+	if packet.ChannelID != "" {
+		return packet.ChannelID
+	}
+
+	return packet.GuildID
+}
+
+// readScopedPacket reads the guild and channel IDs from the event. Events
+// whose payload is a guild carry its ID in `id` instead of `guild_id`.
+func readScopedPacket(event string, data []byte) (*guildScopedPacket, bool) {
+	packet := &guildScopedPacket{}
+	if err := packet.UnmarshalJSON(data); err != nil {
+		return nil, false
+	}
+
+	switch event {
+	case events.GuildCreateStr, events.GuildUpdateStr, events.GuildDeleteStr:
+		packet.GuildID = packet.ID
+	}
+
+	return packet, true
+}
+
+// dispatch is an event waiting to be passed to handlers.
+type dispatch struct {
+	event string
+	data  []byte
+}
+
+// dispatchLane is the list of events waiting for a worker. It's unbounded
+// so that slow handlers never hold up reading from the socket, which would
+// hold up heartbeats too.
+type dispatchLane struct {
+	mu     sync.Mutex
+	items  []dispatch
+	notify chan struct{}
+}
+
+// Push appends the event to the lane and wakes its worker.
+func (l *dispatchLane) Push(d dispatch) {
+	l.mu.Lock()
+	l.items = append(l.items, d)
+	l.mu.Unlock()
+
+	select {
+	case l.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Take removes and returns every event in the lane.
+func (l *dispatchLane) Take() []dispatch {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	items := l.items
+	l.items = nil
+	return items
+}
+
+// dispatcher passes events to the emitter from a fixed set of workers.
+// Each worker handles the events in its lane one at a time, in the order
+// they were pushed, and events with the same key always go to the same
+// lane.
+type dispatcher struct {
+	events *emitter
+	key    func(event string, data []byte) string
+	lanes  []*dispatchLane
+}
+
+func newDispatcher(events *emitter, workers int, key func(event string, data []byte) string) *dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+
+	d := &dispatcher{events: events, key: key, lanes: make([]*dispatchLane, workers)}
+	for i := range d.lanes {
+		d.lanes[i] = &dispatchLane{notify: make(chan struct{}, 1)}
+	}
+
+	return d
+}
+
+// Start runs the workers until done is closed. Events which haven't been
+// handled by then are dropped. Errors returned from handlers are passed
+// to onErr.
+func (d *dispatcher) Start(done <-chan struct{}, onErr func(err error)) {
+	for _, lane := range d.lanes {
+		go d.work(lane, done, onErr)
+	}
+}
+
+// Push queues the event to be passed to handlers.
+func (d *dispatcher) Push(event string, data []byte) {
+	d.lane(event, data).Push(dispatch{event, data})
+}
+
+// lane returns the lane which handles the event.
+func (d *dispatcher) lane(event string, data []byte) *dispatchLane {
+	if len(d.lanes) == 1 {
+		return d.lanes[0]
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(d.key(event, data)))
+	return d.lanes[h.Sum32()%uint32(len(d.lanes))]
+}
+
+func (d *dispatcher) work(lane *dispatchLane, done <-chan struct{}, onErr func(err error)) {
+	for {
+		select {
+		case <-lane.notify:
+		case <-done:
+			return
+		}
+
+		for _, item := range lane.Take() {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if err := d.events.Dispatch(item.event, item.data); err != nil {
+				onErr(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package cord
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/cord/events"
+	"github.com/WatchBeam/cord/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatchKeys(t *testing.T) {
+	both := []byte(`{"guild_id":"1","channel_id":"2","nested":{"guild_id":"3"}}`)
+	assert.Equal(t, "1", GuildKey("MESSAGE_CREATE", both))
+	assert.Equal(t, "2", ChannelKey("MESSAGE_CREATE", both))
+
+	dm := []byte(`{"channel_id":"2"}`)
+	assert.Equal(t, "2", GuildKey("MESSAGE_CREATE", dm))
+	assert.Equal(t, "", GuildKey("MESSAGE_CREATE", []byte(`[]`)))
+
+	guild := []byte(`{"id":"1","channels":[{"id":"2"}]}`)
+	assert.Equal(t, "1", GuildKey(events.GuildCreateStr, guild))
+	assert.Equal(t, "1", GuildKey(events.GuildDeleteStr, []byte(`{"id":"1","unavailable":true}`)))
+	assert.Equal(t, "1", ChannelKey(events.GuildUpdateStr, guild))
+	assert.Equal(t, "", GuildKey("MESSAGE_CREATE", []byte(`{"id":"1"}`)))
+}
+
+func TestDispatcherKeepsOrder(t *testing.T) {
+	e := newEmitter()
+	d := newDispatcher(e, 1, GuildKey)
+
+	var got []string
+	all := make(chan struct{})
+	e.On(events.MessageCreate(func(m *model.Message) {
+		got = append(got, m.ID)
+		if len(got) == 100 {
+			close(all)
+		}
+	}))
+
+	done := make(chan struct{})
+	defer close(done)
+	d.Start(done, func(err error) { t.Error(err) })
+
+	var want []string
+	for i := 0; i < 100; i++ {
+		want = append(want, strconv.Itoa(i))
+		d.Push(events.MessageCreateStr, []byte(`{"id":"`+strconv.Itoa(i)+`"}`))
+	}
+
+	<-all
+	assert.Equal(t, want, got)
+}
+
+func TestDispatcherOrdersWorkersByKey(t *testing.T) {
+	e := newEmitter()
+	d := newDispatcher(e, 4, ChannelKey)
+
+	// Pick two channels which land in different lanes.
+	channels := []string{"0"}
+	first := d.lane(events.MessageCreateStr, []byte(`{"channel_id":"0"}`))
+	for i := 1; len(channels) < 2; i++ {
+		id := strconv.Itoa(i)
+		if d.lane(events.MessageCreateStr, []byte(`{"channel_id":"`+id+`"}`)) != first {
+			channels = append(channels, id)
+		}
+	}
+
+	var mu sync.Mutex
+	got := map[string][]string{}
+	unblock := make(chan struct{})
+	finished := make(chan struct{}, 20)
+	e.On(events.MessageCreate(func(m *model.Message) {
+		// The first channel is held up until the second is done, so both
+		// must be handled concurrently.
+		if m.ChannelID == channels[0] && m.ID == "0" {
+			<-unblock
+		}
+
+		mu.Lock()
+		got[m.ChannelID] = append(got[m.ChannelID], m.ID)
+		mu.Unlock()
+		finished <- struct{}{}
+	}))
+
+	done := make(chan struct{})
+	defer close(done)
+	d.Start(done, func(err error) { t.Error(err) })
+
+	for i := 0; i < 10; i++ {
+		for _, channel := range channels {
+			d.Push(events.MessageCreateStr, []byte(fmt.Sprintf(`{"id":"%d","channel_id":"%s"}`, i, channel)))
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		select {
+		case <-finished:
+		case <-time.After(time.Second):
+			t.Fatal("expected the second channel to be handled while the first is blocked")
+		}
+	}
+	close(unblock)
+	for i := 0; i < 10; i++ {
+		<-finished
+	}
+
+	want := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+	assert.Equal(t, want, got[channels[0]])
+	assert.Equal(t, want, got[channels[1]])
+}
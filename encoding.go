@@ -0,0 +1,35 @@
+package cord
+
+import "github.com/WatchBeam/cord/etf"
+
+// An Encoding determines the format of payloads sent over the gateway.
+type Encoding uint8
+
+const (
+	// EncodingJSON sends payloads as JSON text. This is the default.
+	EncodingJSON Encoding = iota
+	// EncodingETF sends payloads in Erlang's External Term Format, which is
+	// more compact than JSON. Payloads are converted to JSON as they're
+	// received, so handlers and the Debugger see the same data as they
+	// would with EncodingJSON. Snowflakes, which ETF sends as integers, are
+	// converted to strings.
+	EncodingETF
+)
+
+// encode converts a JSON payload into the encoding.
+func (e Encoding) encode(b []byte) ([]byte, error) {
+	if e == EncodingETF {
+		return etf.FromJSON(b)
+	}
+
+	return b, nil
+}
+
+// decode converts a payload in the encoding into JSON.
+func (e Encoding) decode(b []byte) ([]byte, error) {
+	if e == EncodingETF {
+		return etf.ToJSON(b)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,52 @@
+package cord
+
+import "sync"
+
+// errChannel is the channel returned from Errs(). Unlike a plain channel,
+// it may be closed while errors are being sent on it: sends which haven't
+// been received are dropped.
+type errChannel struct {
+	mu       sync.RWMutex
+	ch       chan error
+	done     chan struct{}
+	doneOnce sync.Once
+	closed   bool
+}
+
+func newErrChannel() *errChannel {
+	return &errChannel{
+		ch:   make(chan error),
+		done: make(chan struct{}),
+	}
+}
+
+// Send blocks until the error is received or the channel is closed.
+func (e *errChannel) Send(err error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.closed {
+		return
+	}
+
+	select {
+	case e.ch <- err:
+	case <-e.done:
+	}
+}
+
+// Close closes the channel, unblocking any pending sends. It's safe to
+// call more than once.
+func (e *errChannel) Close() {
+	// Closing done first releases blocked senders, so that we can then
+	// take the write lock to wait for them to finish.
+	e.doneOnce.Do(func() { close(e.done) })
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.closed {
+		e.closed = true
+		close(e.ch)
+	}
+}
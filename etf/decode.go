@@ -0,0 +1,355 @@
+package etf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/mailru/easyjson/jwriter"
+)
+
+// ToJSON converts an encoded term into JSON.
+func ToJSON(b []byte) ([]byte, error) {
+	if len(b) == 0 || b[0] != Version {
+		return nil, ErrVersion
+	}
+
+	d := &decoder{data: b, pos: 1}
+	if err := d.term(); err != nil {
+		return nil, err
+	}
+
+	return d.out.BuildBytes()
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+	out  jwriter.Writer
+}
+
+// read consumes and returns the next n bytes.
+func (d *decoder) read(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, ErrTruncated
+	}
+
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint8() (int, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(b[0]), nil
+}
+
+func (d *decoder) uint16() (int, error) {
+	b, err := d.read(2)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(binary.BigEndian.Uint16(b)), nil
+}
+
+func (d *decoder) uint32() (int, error) {
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(binary.BigEndian.Uint32(b)), nil
+}
+
+// term decodes the next term and writes it to the output.
+func (d *decoder) term() error {
+	tag, err := d.uint8()
+	if err != nil {
+		return err
+	}
+
+	switch tag {
+	case tagSmallInteger:
+		n, err := d.uint8()
+		d.out.Int(n)
+		return err
+
+	case tagInteger:
+		b, err := d.read(4)
+		if err != nil {
+			return err
+		}
+		d.out.Int32(int32(binary.BigEndian.Uint32(b)))
+
+	case tagNewFloat:
+		b, err := d.read(8)
+		if err != nil {
+			return err
+		}
+		d.out.Float64(math.Float64frombits(binary.BigEndian.Uint64(b)))
+
+	case tagFloat:
+		b, err := d.read(31)
+		if err != nil {
+			return err
+		}
+		f, err := strconv.ParseFloat(string(bytes.TrimRight(b, "\x00")), 64)
+		if err != nil {
+			return fmt.Errorf("etf: invalid float: %s", err)
+		}
+		d.out.Float64(f)
+
+	case tagAtom, tagAtomUTF8:
+		return d.atom(d.uint16)
+
+	case tagSmallAtom, tagSmallAtomUTF8:
+		return d.atom(d.uint8)
+
+	case tagBinary:
+		return d.binary(d.uint32)
+
+	case tagString:
+		return d.binary(d.uint16)
+
+	case tagNil:
+		d.out.RawString("[]")
+
+	case tagSmallTuple:
+		n, err := d.uint8()
+		if err != nil {
+			return err
+		}
+		return d.array(n)
+
+	case tagLargeTuple:
+		n, err := d.uint32()
+		if err != nil {
+			return err
+		}
+		return d.array(n)
+
+	case tagList:
+		n, err := d.uint32()
+		if err != nil {
+			return err
+		}
+		if err := d.array(n); err != nil {
+			return err
+		}
+		return d.listTail()
+
+	case tagMap:
+		n, err := d.uint32()
+		if err != nil {
+			return err
+		}
+		return d.object(n)
+
+	case tagSmallBig:
+		n, err := d.uint8()
+		if err != nil {
+			return err
+		}
+		return d.bigInt(n)
+
+	case tagLargeBig:
+		n, err := d.uint32()
+		if err != nil {
+			return err
+		}
+		return d.bigInt(n)
+
+	case tagCompressed:
+		return d.compressed()
+
+	default:
+		return fmt.Errorf("etf: unsupported tag %d", tag)
+	}
+
+	return nil
+}
+
+// atom writes an atom whose length is read using the given function.
+func (d *decoder) atom(length func() (int, error)) error {
+	n, err := length()
+	if err != nil {
+		return err
+	}
+
+	b, err := d.read(n)
+	if err != nil {
+		return err
+	}
+
+	switch string(b) {
+	case "nil", "null":
+		d.out.RawString("null")
+	case "true":
+		d.out.Bool(true)
+	case "false":
+		d.out.Bool(false)
+	default:
+		d.out.String(string(b))
+	}
+
+	return nil
+}
+
+// binary writes a string whose length is read using the given function.
+func (d *decoder) binary(length func() (int, error)) error {
+	n, err := length()
+	if err != nil {
+		return err
+	}
+
+	b, err := d.read(n)
+	if err != nil {
+		return err
+	}
+
+	d.out.String(string(b))
+	return nil
+}
+
+// array writes the next n terms as a JSON array.
+func (d *decoder) array(n int) error {
+	d.out.RawByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			d.out.RawByte(',')
+		}
+		if err := d.term(); err != nil {
+			return err
+		}
+	}
+	d.out.RawByte(']')
+
+	return nil
+}
+
+// listTail consumes the tail of a list. Proper lists, which are the only
+// kind with a JSON equivalent, end with an empty list.
+func (d *decoder) listTail() error {
+	tag, err := d.uint8()
+	if err != nil {
+		return err
+	}
+
+	if tag != tagNil {
+		return fmt.Errorf("etf: improper lists are not supported")
+	}
+
+	return nil
+}
+
+// object writes the next n pairs of terms as a JSON object.
+func (d *decoder) object(n int) error {
+	d.out.RawByte('{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			d.out.RawByte(',')
+		}
+		if err := d.key(); err != nil {
+			return err
+		}
+		d.out.RawByte(':')
+		if err := d.term(); err != nil {
+			return err
+		}
+	}
+	d.out.RawByte('}')
+
+	return nil
+}
+
+// key writes a map key, which JSON requires to be a string. Keys which
+// aren't already strings are written as the string of their JSON.
+func (d *decoder) key() error {
+	inner := &decoder{data: d.data, pos: d.pos}
+	if err := inner.term(); err != nil {
+		return err
+	}
+	d.pos = inner.pos
+
+	b, err := inner.out.BuildBytes()
+	if err != nil {
+		return err
+	}
+
+	if len(b) > 0 && b[0] == '"' {
+		d.out.Raw(b, nil)
+	} else {
+		d.out.String(string(b))
+	}
+
+	return nil
+}
+
+// bigInt writes a big integer of n bytes as a decimal string.
+func (d *decoder) bigInt(n int) error {
+	sign, err := d.uint8()
+	if err != nil {
+		return err
+	}
+
+	b, err := d.read(n)
+	if err != nil {
+		return err
+	}
+
+	// Digits are stored little-endian, big.Int wants them big-endian.
+	digits := make([]byte, n)
+	for i, digit := range b {
+		digits[n-1-i] = digit
+	}
+
+	v := new(big.Int).SetBytes(digits)
+	if sign != 0 {
+		v.Neg(v)
+	}
+
+	d.out.String(v.String())
+	return nil
+}
+
+// compressed inflates a zlib-compressed term and decodes it.
+func (d *decoder) compressed() error {
+	size, err := d.uint32()
+	if err != nil {
+		return err
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(d.data[d.pos:]))
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if len(b) != size {
+		return fmt.Errorf("etf: compressed term was %d bytes, expected %d", len(b), size)
+	}
+
+	inner := &decoder{data: b}
+	if err := inner.term(); err != nil {
+		return err
+	}
+	d.pos = len(d.data)
+
+	out, err := inner.out.BuildBytes()
+	d.out.Raw(out, err)
+	return err
+}
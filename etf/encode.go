@@ -0,0 +1,154 @@
+package etf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// FromJSON converts JSON into an encoded term. Objects are encoded as maps
+// with binary keys, and strings as binaries.
+func FromJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	e := &encoder{buf: []byte{Version}}
+	if err := e.term(v); err != nil {
+		return nil, err
+	}
+
+	return e.buf, nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) uint32(n int) {
+	e.buf = append(e.buf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func (e *encoder) atom(name string) {
+	e.buf = append(e.buf, tagSmallAtomUTF8, byte(len(name)))
+	e.buf = append(e.buf, name...)
+}
+
+// term encodes a value decoded by encoding/json.
+func (e *encoder) term(v interface{}) error {
+	switch t := v.(type) {
+	case nil:
+		e.atom("nil")
+
+	case bool:
+		if t {
+			e.atom("true")
+		} else {
+			e.atom("false")
+		}
+
+	case string:
+		e.buf = append(e.buf, tagBinary)
+		e.uint32(len(t))
+		e.buf = append(e.buf, t...)
+
+	case json.Number:
+		return e.number(t)
+
+	case []interface{}:
+		if len(t) == 0 {
+			e.buf = append(e.buf, tagNil)
+			return nil
+		}
+
+		e.buf = append(e.buf, tagList)
+		e.uint32(len(t))
+		for _, item := range t {
+			if err := e.term(item); err != nil {
+				return err
+			}
+		}
+		e.buf = append(e.buf, tagNil)
+
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for key := range t {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		e.buf = append(e.buf, tagMap)
+		e.uint32(len(t))
+		for _, key := range keys {
+			if err := e.term(key); err != nil {
+				return err
+			}
+			if err := e.term(t[key]); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("etf: cannot encode %T", v)
+	}
+
+	return nil
+}
+
+// number encodes a JSON number as the smallest integer type which fits it,
+// or as a float if it isn't an integer.
+func (e *encoder) number(n json.Number) error {
+	if i, ok := new(big.Int).SetString(string(n), 10); ok {
+		e.integer(i)
+		return nil
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return err
+	}
+
+	e.buf = append(e.buf, tagNewFloat)
+	e.buf = append(e.buf, make([]byte, 8)...)
+	binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], math.Float64bits(f))
+	return nil
+}
+
+func (e *encoder) integer(i *big.Int) {
+	switch {
+	case i.Sign() >= 0 && i.Cmp(big.NewInt(math.MaxUint8)) <= 0:
+		e.buf = append(e.buf, tagSmallInteger, byte(i.Int64()))
+
+	case i.IsInt64() && i.Int64() >= math.MinInt32 && i.Int64() <= math.MaxInt32:
+		e.buf = append(e.buf, tagInteger)
+		e.uint32(int(uint32(int32(i.Int64()))))
+
+	default:
+		digits := new(big.Int).Abs(i).Bytes()
+		sign := byte(0)
+		if i.Sign() < 0 {
+			sign = 1
+		}
+
+		if len(digits) > math.MaxUint8 {
+			e.buf = append(e.buf, tagLargeBig)
+			e.uint32(len(digits))
+		} else {
+			e.buf = append(e.buf, tagSmallBig, byte(len(digits)))
+		}
+		e.buf = append(e.buf, sign)
+
+		// Digits are stored little-endian.
+		for j := len(digits) - 1; j >= 0; j-- {
+			e.buf = append(e.buf, digits[j])
+		}
+	}
+}
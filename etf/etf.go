@@ -0,0 +1,46 @@
+// Package etf converts between JSON and Erlang's External Term Format,
+// which Discord's gateway speaks when connected with `encoding=etf`.
+//
+// Cord's models are decoded from JSON, so rather than decoding ETF into Go
+// values directly this package transcodes it: terms received from the
+// gateway are converted into the equivalent JSON, and JSON we send is
+// converted into terms. Atoms `nil`, `true` and `false` map to null and
+// booleans, binaries and other atoms map to strings, and tuples and lists
+// map to arrays. Big integers, which Discord uses for snowflakes, are
+// written as strings to match the JSON gateway.
+package etf
+
+import "errors"
+
+// Version is the first byte of every encoded term.
+const Version = 131
+
+const (
+	tagNewFloat      = 70
+	tagCompressed    = 80
+	tagSmallInteger  = 97
+	tagInteger       = 98
+	tagFloat         = 99
+	tagAtom          = 100
+	tagSmallTuple    = 104
+	tagLargeTuple    = 105
+	tagNil           = 106
+	tagString        = 107
+	tagList          = 108
+	tagBinary        = 109
+	tagSmallBig      = 110
+	tagLargeBig      = 111
+	tagSmallAtom     = 115
+	tagMap           = 116
+	tagAtomUTF8      = 118
+	tagSmallAtomUTF8 = 119
+)
+
+var (
+	// ErrVersion is returned when decoding data which doesn't start with
+	// the ETF version byte.
+	ErrVersion = errors.New("etf: unsupported format version")
+	// ErrTruncated is returned when decoding data which ends in the middle
+	// of a term.
+	ErrTruncated = errors.New("etf: unexpected end of data")
+)
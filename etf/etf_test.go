@@ -0,0 +1,84 @@
+package etf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundTripsJSON(t *testing.T) {
+	tt := []string{
+		`null`,
+		`true`,
+		`{"a":1,"b":[1,2,3],"c":{"d":"e"},"f":null,"g":false}`,
+		`{"op":2,"d":{"token":"Bot abc","properties":{"$os":"linux"},"large_threshold":250}}`,
+		`[]`,
+		`-42`,
+		`70000`,
+		`1.5`,
+		`"snow☃man"`,
+	}
+
+	for _, in := range tt {
+		b, err := FromJSON([]byte(in))
+		assert.Nil(t, err, in)
+		out, err := ToJSON(b)
+		assert.Nil(t, err, in)
+		assert.JSONEq(t, in, string(out))
+	}
+}
+
+func TestEncodesBigIntegers(t *testing.T) {
+	b, err := FromJSON([]byte(`41771983423143937`))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{Version, tagSmallBig, 7, 0, 0x01, 0x00, 0x80, 0xc9, 0x65, 0x67, 0x94}, b)
+
+	out, err := ToJSON(b)
+	assert.Nil(t, err)
+	assert.Equal(t, `"41771983423143937"`, string(out))
+}
+
+func TestDecodesTermsWithoutJSONEquivalents(t *testing.T) {
+	tt := []struct {
+		in  []byte
+		out string
+	}{
+		{[]byte{Version, tagSmallTuple, 2, tagSmallInteger, 1, tagAtom, 0, 2, 'o', 'k'}, `[1,"ok"]`},
+		{[]byte{Version, tagString, 0, 3, 1, 2, 3}, `"\u0001\u0002\u0003"`},
+		{[]byte{Version, tagMap, 0, 0, 0, 1, tagSmallInteger, 5, tagNil}, `{"5":[]}`},
+		{[]byte{Version, tagSmallBig, 1, 1, 5}, `"-5"`},
+	}
+
+	for _, test := range tt {
+		out, err := ToJSON(test.in)
+		assert.Nil(t, err)
+		assert.Equal(t, test.out, string(out))
+	}
+}
+
+func TestDecodesCompressedTerms(t *testing.T) {
+	term := []byte{tagList, 0, 0, 0, 2, tagSmallInteger, 1, tagSmallInteger, 2, tagNil}
+
+	buf := bytes.NewBuffer(nil)
+	zw := zlib.NewWriter(buf)
+	zw.Write(term)
+	zw.Close()
+
+	in := []byte{Version, tagCompressed, 0, 0, 0, byte(len(term))}
+	out, err := ToJSON(append(in, buf.Bytes()...))
+	assert.Nil(t, err)
+	assert.Equal(t, `[1,2]`, string(out))
+}
+
+func TestRejectsInvalidTerms(t *testing.T) {
+	_, err := ToJSON([]byte(`{}`))
+	assert.Equal(t, ErrVersion, err)
+
+	_, err = ToJSON([]byte{Version, tagBinary, 0xff, 0xff, 0xff, 0xff, 'a'})
+	assert.Equal(t, ErrTruncated, err)
+
+	_, err = ToJSON([]byte{Version, tagList, 0, 0, 0, 1, tagNil, tagSmallInteger, 1})
+	assert.NotNil(t, err)
+}
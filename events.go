@@ -1,85 +1,364 @@
 package cord
 
 import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"strings"
 	"sync"
 
 	"github.com/WatchBeam/cord/events"
 )
 
-type handlerList []events.Handler
+// A DispatchFunc passes an event to the handlers listening for it.
+type DispatchFunc func(event string, data []byte) error
 
-func (h handlerList) Delete(handler events.Handler) []events.Handler {
-	for i, other := range h {
-		if other != handler {
-			continue
-		}
+// A Middleware wraps the dispatch of every event. It may inspect or replace
+// the event before passing it to next, drop it by not calling next, or act
+// on the error next returns. The data must not be modified, but may be
+// replaced. If a middleware panics, the panic is recovered and the event
+// is dropped.
+type Middleware func(event string, data []byte, next DispatchFunc) error
+
+// A HandlerPanicError is sent when an event handler panics. The panic is
+// recovered, and the event is still passed to the remaining handlers.
+type HandlerPanicError struct {
+	// Event is the name of the event being handled.
+	Event string
+	// Handler is the handler which panicked.
+	Handler events.Handler
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+// Error implements error.Error
+func (h HandlerPanicError) Error() string {
+	return fmt.Sprintf("cord/websocket: %s handler for %s panicked: %v", handlerName(h.Handler), h.Event, h.Value)
+}
+
+// handlerName describes the handler in errors. Func handlers, which share
+// a type for each event, are told apart by the name of the func.
+func handlerName(h events.Handler) string {
+	v := reflect.ValueOf(h)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return fmt.Sprintf("%T", h)
+	}
 
-		h[i] = h[len(h)-1]
-		h[len(h)-1] = nil
-		return h[:len(h)-1]
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return fmt.Sprintf("%T", h)
 	}
 
-	return h
+	return fmt.Sprintf("%T %s", h, fn.Name())
+}
+
+// A MiddlewarePanicError is sent when a middleware panics. The panic is
+// recovered, and the event is dropped.
+type MiddlewarePanicError struct {
+	// Event is the name of the event being dispatched.
+	Event string
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+// Error implements error.Error
+func (m MiddlewarePanicError) Error() string {
+	return fmt.Sprintf("cord/websocket: middleware for %s panicked: %v", m.Event, m.Value)
+}
+
+// A HandlerError wraps an error returned from an event handler, usually
+// because it failed to unmarshal the event.
+type HandlerError struct {
+	// Event is the name of the event being handled.
+	Event string
+	// Handler is the handler which failed.
+	Handler events.Handler
+	// Err is the error it returned.
+	Err error
+}
+
+// Error implements error.Error
+func (h HandlerError) Error() string {
+	return fmt.Sprintf("cord/websocket: %s handler for %s failed: %s", handlerName(h.Handler), h.Event, h.Err)
+}
+
+// HandlerErrors is returned from dispatching an event when any of its
+// handlers failed. Each error is a HandlerError or a HandlerPanicError. A
+// payload which can't be decoded is reported once, for the first of the
+// Decoders which share it.
+// Errors are also sent down the Errs() channel one by one.
+type HandlerErrors []error
+
+// Error implements error.Error
+func (h HandlerErrors) Error() string {
+	if len(h) == 1 {
+		return h[0].Error()
+	}
+
+	msgs := make([]string, len(h))
+	for i, err := range h {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("cord/websocket: %d handlers failed: %s", len(h), strings.Join(msgs, "; "))
+}
+
+// A Subscription is returned when attaching a handler, to detach it.
+type Subscription interface {
+	// Unsubscribe detaches the handler. It's safe to call more than once,
+	// including after a Once handler was called.
+	Unsubscribe()
+}
+
+type subscription struct {
+	emitter *emitter
+	name    string
+	id      uint64
+}
+
+// Unsubscribe implements Subscription.Unsubscribe
+func (s subscription) Unsubscribe() { s.emitter.remove(s.name, s.id) }
+
+// subscriber is a handler attached to the emitter.
+type subscriber struct {
+	id      uint64
+	handler events.Handler
+	once    bool
 }
 
 // emitter is a simple eventemitter-like interface which
 // contains events.Handler interfaces.
 type emitter struct {
-	mu       *sync.Mutex
-	onces    map[string]handlerList
-	handlers map[string]handlerList
+	mu         sync.Mutex
+	nextID     uint64
+	handlers   map[string][]subscriber
+	middleware []Middleware
 }
 
-func newEmitter() emitter {
-	return emitter{
-		mu:       new(sync.Mutex),
-		onces:    make(map[string]handlerList),
-		handlers: make(map[string]handlerList),
-	}
+func newEmitter() *emitter {
+	return &emitter{handlers: make(map[string][]subscriber)}
 }
 
 // On attaches a events.Handler so that it's called every time an event is received.
-func (e *emitter) On(h events.Handler) {
+func (e *emitter) On(h events.Handler) Subscription { return e.add(h, false) }
+
+// Once attaches a handler that's called the next time the event is received,
+// then immediately removed.
+func (e *emitter) Once(h events.Handler) Subscription { return e.add(h, true) }
+
+func (e *emitter) add(h events.Handler, once bool) Subscription {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	e.handlers[h.Name()] = append(e.handlers[h.Name()], h)
+	e.nextID++
+	name := h.Name()
+	e.handlers[name] = append(e.handlers[name], subscriber{e.nextID, h, once})
+
+	return subscription{e, name, e.nextID}
 }
 
-// Onces attaches a handler that's called the next time the event is received,
-// then immediately removed.
-func (e *emitter) Once(h events.Handler) {
+// remove detaches the handler with the given ID, if it's still attached.
+func (e *emitter) remove(name string, id uint64) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	e.onces[h.Name()] = append(e.onces[h.Name()], h)
+	e.filter(name, func(s subscriber) bool { return s.id != id })
 }
 
-// Off removes a listening handler.
+// Off removes every attachment of a listening handler. Handlers which
+// can't be compared, such as funcs, are never removed; use the handler's
+// Subscription instead.
 func (e *emitter) Off(h events.Handler) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	e.handlers[h.Name()] = e.handlers[h.Name()].Delete(h)
-	e.onces[h.Name()] = e.onces[h.Name()].Delete(h)
+	e.filter(h.Name(), func(s subscriber) bool { return !sameHandler(s.handler, h) })
+}
+
+// filter keeps only the event's subscribers for which keep returns true.
+// It must be called while holding mu.
+func (e *emitter) filter(name string, keep func(s subscriber) bool) {
+	var kept []subscriber
+	for _, s := range e.handlers[name] {
+		if keep(s) {
+			kept = append(kept, s)
+		}
+	}
+
+	if len(kept) == 0 {
+		delete(e.handlers, name)
+	} else {
+		e.handlers[name] = kept
+	}
+}
+
+// sameHandler returns whether both handlers are equal, or false if they
+// can't be compared.
+func sameHandler(a, b events.Handler) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+
+	return canCompare(reflect.ValueOf(a)) && canCompare(reflect.ValueOf(b)) && a == b
+}
+
+// canCompare returns whether the value can be compared with == without
+// panicking. Unlike reflect.Type.Comparable, it looks at the dynamic values
+// held in interfaces, such as the handler wrapped by events.Copy.
+func canCompare(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Func, reflect.Map, reflect.Slice:
+		return false
+	case reflect.Interface:
+		return v.IsNil() || canCompare(v.Elem())
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !canCompare(v.Index(i)) {
+				return false
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !canCompare(v.Field(i)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Use adds a middleware which wraps every dispatch. Middleware is called in
+// the order it was added, so the first is outermost.
+func (e *emitter) Use(m Middleware) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.middleware = append(e.middleware, m)
 }
 
-// Dispatch invokes all handlers listening on the event with the `b` bytes.
+// Dispatch passes the event through the middleware, then invokes all
+// handlers listening on it with the `b` bytes.
 func (e *emitter) Dispatch(event string, b []byte) error {
 	e.mu.Lock()
-	l1, l2 := e.handlers[event], e.onces[event]
-	e.onces[event] = nil
+	middleware := e.middleware
+	e.mu.Unlock()
 
-	list := make([]events.Handler, len(l1)+len(l2))
-	copy(list, l1)
-	copy(list[len(l1):], l2)
+	next := e.invoke
+	for i := len(middleware) - 1; i >= 0; i-- {
+		m, inner := middleware[i], next
+		next = func(event string, b []byte) error { return invokeMiddleware(m, event, b, inner) }
+	}
+
+	return next(event, b)
+}
+
+// invokeMiddleware calls the middleware, recovering from panics.
+func invokeMiddleware(m Middleware, event string, b []byte, next DispatchFunc) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = MiddlewarePanicError{Event: event, Value: v, Stack: debug.Stack()}
+		}
+	}()
+
+	return m(event, b, next)
+}
+
+// invoke calls all handlers listening on the event with the `b` bytes,
+// followed by those listening on every event. Every handler is called even
+// if others fail or panic, and their errors are returned as HandlerErrors.
+// The payload is decoded once for all Decoders of the same type, which
+// share the result.
+func (e *emitter) invoke(event string, b []byte) error {
+	e.mu.Lock()
+	var list []events.Handler
+	for _, name := range []string{event, events.AnyStr} {
+		once := false
+		for _, s := range e.handlers[name] {
+			list = append(list, s.handler)
+			once = once || s.once
+		}
+		if once {
+			e.filter(name, func(s subscriber) bool { return !s.once })
+		}
+	}
 	e.mu.Unlock()
 
+	cache := decodeCache{}
+	var errs HandlerErrors
 	for _, handler := range list {
-		if err := handler.Invoke(b); err != nil {
-			return err
+		if err := invokeHandler(event, handler, b, cache); err != nil {
+			errs = append(errs, err)
 		}
 	}
 
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// invokeHandler calls the handler, recovering from panics. Decoders get
+// their payload from the cache.
+func invokeHandler(event string, h events.Handler, b []byte, cache decodeCache) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = HandlerPanicError{Event: event, Handler: h, Value: v, Stack: debug.Stack()}
+		}
+	}()
+
+	if n, ok := h.(events.NamedHandler); ok {
+		if err := n.InvokeNamed(event, b); err != nil {
+			return HandlerError{Event: event, Handler: h, Err: err}
+		}
+
+		return nil
+	}
+
+	if d, ok := h.(events.Decoder); ok {
+		result, shared := cache.Decode(d, b)
+		if result.err != nil && shared {
+			return nil // Already reported for the first Decoder.
+		} else if result.err != nil {
+			return HandlerError{Event: event, Handler: h, Err: result.err}
+		}
+
+		d.InvokeDecoded(result.v)
+		return nil
+	}
+
+	if err := h.Invoke(b); err != nil {
+		return HandlerError{Event: event, Handler: h, Err: err}
+	}
+
 	return nil
 }
+
+// decodeCache holds the payloads decoded for an event, by the type of the
+// Decoder which decoded them.
+type decodeCache map[reflect.Type]decoded
+
+type decoded struct {
+	v   interface{}
+	err error
+}
+
+// Decode returns the payload decoded by the first Decoder of the same
+// type, decoding it if this is the first. It also returns whether the
+// result was shared from an earlier Decoder.
+func (c decodeCache) Decode(d events.Decoder, b []byte) (decoded, bool) {
+	t := reflect.TypeOf(d)
+	if result, ok := c[t]; ok {
+		return result, true
+	}
+
+	v, err := d.Decode(b)
+	c[t] = decoded{v, err}
+	return c[t], false
+}
@@ -35,10 +35,11 @@ var (
 	VoiceStateUpdateStr        = "VOICE_STATE_UPDATE"
 )
 
-// ChannelCreate is a handler for CHANNEL_CREATE events.
+// ChannelCreate is a handler for CHANNEL_CREATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type ChannelCreate func(update *model.Channel)
 
-var _ Handler = ChannelCreate(func(m *model.Channel) {})
+var _ Decoder = ChannelCreate(func(m *model.Channel) {})
 
 // Name implements Handler.Name
 func (p ChannelCreate) Name() string { return ChannelCreateStr }
@@ -54,10 +55,24 @@ func (p ChannelCreate) Invoke(b []byte) error {
 	return nil
 }
 
-// ChannelUpdate is a handler for CHANNEL_UPDATE events.
+// Decode implements Decoder.Decode
+func (p ChannelCreate) Decode(b []byte) (interface{}, error) {
+	data := &model.Channel{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p ChannelCreate) InvokeDecoded(v interface{}) { p(v.(*model.Channel)) }
+
+// ChannelUpdate is a handler for CHANNEL_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type ChannelUpdate func(update *model.Channel)
 
-var _ Handler = ChannelUpdate(func(m *model.Channel) {})
+var _ Decoder = ChannelUpdate(func(m *model.Channel) {})
 
 // Name implements Handler.Name
 func (p ChannelUpdate) Name() string { return ChannelUpdateStr }
@@ -73,10 +88,24 @@ func (p ChannelUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// ChannelDelete is a handler for CHANNEL_DELETE events.
+// Decode implements Decoder.Decode
+func (p ChannelUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.Channel{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p ChannelUpdate) InvokeDecoded(v interface{}) { p(v.(*model.Channel)) }
+
+// ChannelDelete is a handler for CHANNEL_DELETE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type ChannelDelete func(update *model.Channel)
 
-var _ Handler = ChannelDelete(func(m *model.Channel) {})
+var _ Decoder = ChannelDelete(func(m *model.Channel) {})
 
 // Name implements Handler.Name
 func (p ChannelDelete) Name() string { return ChannelDeleteStr }
@@ -92,10 +121,24 @@ func (p ChannelDelete) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildCreate is a handler for GUILD_CREATE events.
+// Decode implements Decoder.Decode
+func (p ChannelDelete) Decode(b []byte) (interface{}, error) {
+	data := &model.Channel{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p ChannelDelete) InvokeDecoded(v interface{}) { p(v.(*model.Channel)) }
+
+// GuildCreate is a handler for GUILD_CREATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildCreate func(update *model.Guild)
 
-var _ Handler = GuildCreate(func(m *model.Guild) {})
+var _ Decoder = GuildCreate(func(m *model.Guild) {})
 
 // Name implements Handler.Name
 func (p GuildCreate) Name() string { return GuildCreateStr }
@@ -111,10 +154,24 @@ func (p GuildCreate) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildUpdate is a handler for GUILD_UPDATE events.
+// Decode implements Decoder.Decode
+func (p GuildCreate) Decode(b []byte) (interface{}, error) {
+	data := &model.Guild{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildCreate) InvokeDecoded(v interface{}) { p(v.(*model.Guild)) }
+
+// GuildUpdate is a handler for GUILD_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildUpdate func(update *model.Guild)
 
-var _ Handler = GuildUpdate(func(m *model.Guild) {})
+var _ Decoder = GuildUpdate(func(m *model.Guild) {})
 
 // Name implements Handler.Name
 func (p GuildUpdate) Name() string { return GuildUpdateStr }
@@ -130,10 +187,24 @@ func (p GuildUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildDelete is a handler for GUILD_DELETE events.
+// Decode implements Decoder.Decode
+func (p GuildUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.Guild{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildUpdate) InvokeDecoded(v interface{}) { p(v.(*model.Guild)) }
+
+// GuildDelete is a handler for GUILD_DELETE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildDelete func(update *model.Guild)
 
-var _ Handler = GuildDelete(func(m *model.Guild) {})
+var _ Decoder = GuildDelete(func(m *model.Guild) {})
 
 // Name implements Handler.Name
 func (p GuildDelete) Name() string { return GuildDeleteStr }
@@ -149,10 +220,24 @@ func (p GuildDelete) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildBanAdd is a handler for GUILD_BAN_ADD events.
+// Decode implements Decoder.Decode
+func (p GuildDelete) Decode(b []byte) (interface{}, error) {
+	data := &model.Guild{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildDelete) InvokeDecoded(v interface{}) { p(v.(*model.Guild)) }
+
+// GuildBanAdd is a handler for GUILD_BAN_ADD events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildBanAdd func(update *model.Guild)
 
-var _ Handler = GuildBanAdd(func(m *model.Guild) {})
+var _ Decoder = GuildBanAdd(func(m *model.Guild) {})
 
 // Name implements Handler.Name
 func (p GuildBanAdd) Name() string { return GuildBanAddStr }
@@ -168,10 +253,24 @@ func (p GuildBanAdd) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildMemberAdd is a handler for GUILD_MEMBER_ADD events.
+// Decode implements Decoder.Decode
+func (p GuildBanAdd) Decode(b []byte) (interface{}, error) {
+	data := &model.Guild{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildBanAdd) InvokeDecoded(v interface{}) { p(v.(*model.Guild)) }
+
+// GuildMemberAdd is a handler for GUILD_MEMBER_ADD events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildMemberAdd func(update *model.Member)
 
-var _ Handler = GuildMemberAdd(func(m *model.Member) {})
+var _ Decoder = GuildMemberAdd(func(m *model.Member) {})
 
 // Name implements Handler.Name
 func (p GuildMemberAdd) Name() string { return GuildMemberAddStr }
@@ -187,10 +286,24 @@ func (p GuildMemberAdd) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildMemberUpdate is a handler for GUILD_MEMBER_UPDATE events.
+// Decode implements Decoder.Decode
+func (p GuildMemberAdd) Decode(b []byte) (interface{}, error) {
+	data := &model.Member{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildMemberAdd) InvokeDecoded(v interface{}) { p(v.(*model.Member)) }
+
+// GuildMemberUpdate is a handler for GUILD_MEMBER_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildMemberUpdate func(update *model.Member)
 
-var _ Handler = GuildMemberUpdate(func(m *model.Member) {})
+var _ Decoder = GuildMemberUpdate(func(m *model.Member) {})
 
 // Name implements Handler.Name
 func (p GuildMemberUpdate) Name() string { return GuildMemberUpdateStr }
@@ -206,10 +319,24 @@ func (p GuildMemberUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildMemberRemove is a handler for GUILD_MEMBER_REMOVE events.
+// Decode implements Decoder.Decode
+func (p GuildMemberUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.Member{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildMemberUpdate) InvokeDecoded(v interface{}) { p(v.(*model.Member)) }
+
+// GuildMemberRemove is a handler for GUILD_MEMBER_REMOVE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildMemberRemove func(update *model.Member)
 
-var _ Handler = GuildMemberRemove(func(m *model.Member) {})
+var _ Decoder = GuildMemberRemove(func(m *model.Member) {})
 
 // Name implements Handler.Name
 func (p GuildMemberRemove) Name() string { return GuildMemberRemoveStr }
@@ -225,10 +352,24 @@ func (p GuildMemberRemove) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildRoleCreate is a handler for GUILD_ROLE_CREATE events.
+// Decode implements Decoder.Decode
+func (p GuildMemberRemove) Decode(b []byte) (interface{}, error) {
+	data := &model.Member{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildMemberRemove) InvokeDecoded(v interface{}) { p(v.(*model.Member)) }
+
+// GuildRoleCreate is a handler for GUILD_ROLE_CREATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildRoleCreate func(update *model.GuildRole)
 
-var _ Handler = GuildRoleCreate(func(m *model.GuildRole) {})
+var _ Decoder = GuildRoleCreate(func(m *model.GuildRole) {})
 
 // Name implements Handler.Name
 func (p GuildRoleCreate) Name() string { return GuildRoleCreateStr }
@@ -244,10 +385,24 @@ func (p GuildRoleCreate) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildRoleUpdate is a handler for GUILD_ROLE_UPDATE events.
+// Decode implements Decoder.Decode
+func (p GuildRoleCreate) Decode(b []byte) (interface{}, error) {
+	data := &model.GuildRole{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildRoleCreate) InvokeDecoded(v interface{}) { p(v.(*model.GuildRole)) }
+
+// GuildRoleUpdate is a handler for GUILD_ROLE_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildRoleUpdate func(update *model.GuildRole)
 
-var _ Handler = GuildRoleUpdate(func(m *model.GuildRole) {})
+var _ Decoder = GuildRoleUpdate(func(m *model.GuildRole) {})
 
 // Name implements Handler.Name
 func (p GuildRoleUpdate) Name() string { return GuildRoleUpdateStr }
@@ -263,10 +418,24 @@ func (p GuildRoleUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildRoleDelete is a handler for GUILD_ROLE_DELETE events.
+// Decode implements Decoder.Decode
+func (p GuildRoleUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.GuildRole{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildRoleUpdate) InvokeDecoded(v interface{}) { p(v.(*model.GuildRole)) }
+
+// GuildRoleDelete is a handler for GUILD_ROLE_DELETE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildRoleDelete func(update *model.GuildRoleDelete)
 
-var _ Handler = GuildRoleDelete(func(m *model.GuildRoleDelete) {})
+var _ Decoder = GuildRoleDelete(func(m *model.GuildRoleDelete) {})
 
 // Name implements Handler.Name
 func (p GuildRoleDelete) Name() string { return GuildRoleDeleteStr }
@@ -282,10 +451,24 @@ func (p GuildRoleDelete) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildIntegrationsUpdate is a handler for GUILD_INTEGRATIONS_UPDATE events.
+// Decode implements Decoder.Decode
+func (p GuildRoleDelete) Decode(b []byte) (interface{}, error) {
+	data := &model.GuildRoleDelete{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildRoleDelete) InvokeDecoded(v interface{}) { p(v.(*model.GuildRoleDelete)) }
+
+// GuildIntegrationsUpdate is a handler for GUILD_INTEGRATIONS_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildIntegrationsUpdate func(update *model.GuildIntegrationsUpdate)
 
-var _ Handler = GuildIntegrationsUpdate(func(m *model.GuildIntegrationsUpdate) {})
+var _ Decoder = GuildIntegrationsUpdate(func(m *model.GuildIntegrationsUpdate) {})
 
 // Name implements Handler.Name
 func (p GuildIntegrationsUpdate) Name() string { return GuildIntegrationsUpdateStr }
@@ -301,10 +484,24 @@ func (p GuildIntegrationsUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// GuildEmojisUpdate is a handler for GUILD_EMOJIS_UPDATE events.
+// Decode implements Decoder.Decode
+func (p GuildIntegrationsUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.GuildIntegrationsUpdate{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildIntegrationsUpdate) InvokeDecoded(v interface{}) { p(v.(*model.GuildIntegrationsUpdate)) }
+
+// GuildEmojisUpdate is a handler for GUILD_EMOJIS_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type GuildEmojisUpdate func(update *model.GuildEmojisUpdate)
 
-var _ Handler = GuildEmojisUpdate(func(m *model.GuildEmojisUpdate) {})
+var _ Decoder = GuildEmojisUpdate(func(m *model.GuildEmojisUpdate) {})
 
 // Name implements Handler.Name
 func (p GuildEmojisUpdate) Name() string { return GuildEmojisUpdateStr }
@@ -320,10 +517,24 @@ func (p GuildEmojisUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// MessageAck is a handler for MESSAGE_ACK events.
+// Decode implements Decoder.Decode
+func (p GuildEmojisUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.GuildEmojisUpdate{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p GuildEmojisUpdate) InvokeDecoded(v interface{}) { p(v.(*model.GuildEmojisUpdate)) }
+
+// MessageAck is a handler for MESSAGE_ACK events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type MessageAck func(update *model.MessageAck)
 
-var _ Handler = MessageAck(func(m *model.MessageAck) {})
+var _ Decoder = MessageAck(func(m *model.MessageAck) {})
 
 // Name implements Handler.Name
 func (p MessageAck) Name() string { return MessageAckStr }
@@ -339,10 +550,24 @@ func (p MessageAck) Invoke(b []byte) error {
 	return nil
 }
 
-// MessageCreate is a handler for MESSAGE_CREATE events.
+// Decode implements Decoder.Decode
+func (p MessageAck) Decode(b []byte) (interface{}, error) {
+	data := &model.MessageAck{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p MessageAck) InvokeDecoded(v interface{}) { p(v.(*model.MessageAck)) }
+
+// MessageCreate is a handler for MESSAGE_CREATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type MessageCreate func(update *model.Message)
 
-var _ Handler = MessageCreate(func(m *model.Message) {})
+var _ Decoder = MessageCreate(func(m *model.Message) {})
 
 // Name implements Handler.Name
 func (p MessageCreate) Name() string { return MessageCreateStr }
@@ -358,10 +583,24 @@ func (p MessageCreate) Invoke(b []byte) error {
 	return nil
 }
 
-// MessageUpdate is a handler for MESSAGE_UPDATE events.
+// Decode implements Decoder.Decode
+func (p MessageCreate) Decode(b []byte) (interface{}, error) {
+	data := &model.Message{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p MessageCreate) InvokeDecoded(v interface{}) { p(v.(*model.Message)) }
+
+// MessageUpdate is a handler for MESSAGE_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type MessageUpdate func(update *model.Message)
 
-var _ Handler = MessageUpdate(func(m *model.Message) {})
+var _ Decoder = MessageUpdate(func(m *model.Message) {})
 
 // Name implements Handler.Name
 func (p MessageUpdate) Name() string { return MessageUpdateStr }
@@ -377,10 +616,24 @@ func (p MessageUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// MessageDelete is a handler for MESSAGE_DELETE events.
+// Decode implements Decoder.Decode
+func (p MessageUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.Message{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p MessageUpdate) InvokeDecoded(v interface{}) { p(v.(*model.Message)) }
+
+// MessageDelete is a handler for MESSAGE_DELETE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type MessageDelete func(update *model.Message)
 
-var _ Handler = MessageDelete(func(m *model.Message) {})
+var _ Decoder = MessageDelete(func(m *model.Message) {})
 
 // Name implements Handler.Name
 func (p MessageDelete) Name() string { return MessageDeleteStr }
@@ -396,10 +649,24 @@ func (p MessageDelete) Invoke(b []byte) error {
 	return nil
 }
 
-// PresenceUpdate is a handler for PRESENCE_UPDATE events.
+// Decode implements Decoder.Decode
+func (p MessageDelete) Decode(b []byte) (interface{}, error) {
+	data := &model.Message{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p MessageDelete) InvokeDecoded(v interface{}) { p(v.(*model.Message)) }
+
+// PresenceUpdate is a handler for PRESENCE_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type PresenceUpdate func(update *model.PresenceUpdate)
 
-var _ Handler = PresenceUpdate(func(m *model.PresenceUpdate) {})
+var _ Decoder = PresenceUpdate(func(m *model.PresenceUpdate) {})
 
 // Name implements Handler.Name
 func (p PresenceUpdate) Name() string { return PresenceUpdateStr }
@@ -415,10 +682,24 @@ func (p PresenceUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// PresencesReplace is a handler for PRESENCES_REPLACE events.
+// Decode implements Decoder.Decode
+func (p PresenceUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.PresenceUpdate{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p PresenceUpdate) InvokeDecoded(v interface{}) { p(v.(*model.PresenceUpdate)) }
+
+// PresencesReplace is a handler for PRESENCES_REPLACE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type PresencesReplace func(update *model.PresencesReplace)
 
-var _ Handler = PresencesReplace(func(m *model.PresencesReplace) {})
+var _ Decoder = PresencesReplace(func(m *model.PresencesReplace) {})
 
 // Name implements Handler.Name
 func (p PresencesReplace) Name() string { return PresencesReplaceStr }
@@ -434,10 +715,24 @@ func (p PresencesReplace) Invoke(b []byte) error {
 	return nil
 }
 
-// Ready is a handler for READY events.
+// Decode implements Decoder.Decode
+func (p PresencesReplace) Decode(b []byte) (interface{}, error) {
+	data := &model.PresencesReplace{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p PresencesReplace) InvokeDecoded(v interface{}) { p(v.(*model.PresencesReplace)) }
+
+// Ready is a handler for READY events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type Ready func(update *model.Ready)
 
-var _ Handler = Ready(func(m *model.Ready) {})
+var _ Decoder = Ready(func(m *model.Ready) {})
 
 // Name implements Handler.Name
 func (p Ready) Name() string { return ReadyStr }
@@ -453,10 +748,24 @@ func (p Ready) Invoke(b []byte) error {
 	return nil
 }
 
-// Resumed is a handler for RESUMED events.
+// Decode implements Decoder.Decode
+func (p Ready) Decode(b []byte) (interface{}, error) {
+	data := &model.Ready{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p Ready) InvokeDecoded(v interface{}) { p(v.(*model.Ready)) }
+
+// Resumed is a handler for RESUMED events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type Resumed func(update *model.Resumed)
 
-var _ Handler = Resumed(func(m *model.Resumed) {})
+var _ Decoder = Resumed(func(m *model.Resumed) {})
 
 // Name implements Handler.Name
 func (p Resumed) Name() string { return ResumedStr }
@@ -472,10 +781,24 @@ func (p Resumed) Invoke(b []byte) error {
 	return nil
 }
 
-// UserUpdate is a handler for USER_UPDATE events.
+// Decode implements Decoder.Decode
+func (p Resumed) Decode(b []byte) (interface{}, error) {
+	data := &model.Resumed{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p Resumed) InvokeDecoded(v interface{}) { p(v.(*model.Resumed)) }
+
+// UserUpdate is a handler for USER_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type UserUpdate func(update *model.User)
 
-var _ Handler = UserUpdate(func(m *model.User) {})
+var _ Decoder = UserUpdate(func(m *model.User) {})
 
 // Name implements Handler.Name
 func (p UserUpdate) Name() string { return UserUpdateStr }
@@ -491,10 +814,24 @@ func (p UserUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// UserSettingsUpdate is a handler for USER_SETTINGS_UPDATE events.
+// Decode implements Decoder.Decode
+func (p UserUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.User{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p UserUpdate) InvokeDecoded(v interface{}) { p(v.(*model.User)) }
+
+// UserSettingsUpdate is a handler for USER_SETTINGS_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type UserSettingsUpdate func(update *model.UserSettingsUpdate)
 
-var _ Handler = UserSettingsUpdate(func(m *model.UserSettingsUpdate) {})
+var _ Decoder = UserSettingsUpdate(func(m *model.UserSettingsUpdate) {})
 
 // Name implements Handler.Name
 func (p UserSettingsUpdate) Name() string { return UserSettingsUpdateStr }
@@ -510,10 +847,24 @@ func (p UserSettingsUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// UserGuildSettingsUpdate is a handler for USER_GUILD_SETTINGS_UPDATE events.
+// Decode implements Decoder.Decode
+func (p UserSettingsUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.UserSettingsUpdate{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p UserSettingsUpdate) InvokeDecoded(v interface{}) { p(v.(*model.UserSettingsUpdate)) }
+
+// UserGuildSettingsUpdate is a handler for USER_GUILD_SETTINGS_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type UserGuildSettingsUpdate func(update *model.UserGuildSettings)
 
-var _ Handler = UserGuildSettingsUpdate(func(m *model.UserGuildSettings) {})
+var _ Decoder = UserGuildSettingsUpdate(func(m *model.UserGuildSettings) {})
 
 // Name implements Handler.Name
 func (p UserGuildSettingsUpdate) Name() string { return UserGuildSettingsUpdateStr }
@@ -529,10 +880,24 @@ func (p UserGuildSettingsUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// TypingStart is a handler for TYPING_START events.
+// Decode implements Decoder.Decode
+func (p UserGuildSettingsUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.UserGuildSettings{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p UserGuildSettingsUpdate) InvokeDecoded(v interface{}) { p(v.(*model.UserGuildSettings)) }
+
+// TypingStart is a handler for TYPING_START events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type TypingStart func(update *model.TypingStart)
 
-var _ Handler = TypingStart(func(m *model.TypingStart) {})
+var _ Decoder = TypingStart(func(m *model.TypingStart) {})
 
 // Name implements Handler.Name
 func (p TypingStart) Name() string { return TypingStartStr }
@@ -548,10 +913,24 @@ func (p TypingStart) Invoke(b []byte) error {
 	return nil
 }
 
-// VoiceServerUpdate is a handler for VOICE_SERVER_UPDATE events.
+// Decode implements Decoder.Decode
+func (p TypingStart) Decode(b []byte) (interface{}, error) {
+	data := &model.TypingStart{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p TypingStart) InvokeDecoded(v interface{}) { p(v.(*model.TypingStart)) }
+
+// VoiceServerUpdate is a handler for VOICE_SERVER_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type VoiceServerUpdate func(update *model.VoiceServerUpdate)
 
-var _ Handler = VoiceServerUpdate(func(m *model.VoiceServerUpdate) {})
+var _ Decoder = VoiceServerUpdate(func(m *model.VoiceServerUpdate) {})
 
 // Name implements Handler.Name
 func (p VoiceServerUpdate) Name() string { return VoiceServerUpdateStr }
@@ -567,10 +946,24 @@ func (p VoiceServerUpdate) Invoke(b []byte) error {
 	return nil
 }
 
-// VoiceStateUpdate is a handler for VOICE_STATE_UPDATE events.
+// Decode implements Decoder.Decode
+func (p VoiceServerUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.VoiceServerUpdate{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p VoiceServerUpdate) InvokeDecoded(v interface{}) { p(v.(*model.VoiceServerUpdate)) }
+
+// VoiceStateUpdate is a handler for VOICE_STATE_UPDATE events. The payload is shared
+// with other handlers and must not be modified; see Copy.
 type VoiceStateUpdate func(update *model.VoiceState)
 
-var _ Handler = VoiceStateUpdate(func(m *model.VoiceState) {})
+var _ Decoder = VoiceStateUpdate(func(m *model.VoiceState) {})
 
 // Name implements Handler.Name
 func (p VoiceStateUpdate) Name() string { return VoiceStateUpdateStr }
@@ -585,3 +978,16 @@ func (p VoiceStateUpdate) Invoke(b []byte) error {
 	p(data)
 	return nil
 }
+
+// Decode implements Decoder.Decode
+func (p VoiceStateUpdate) Decode(b []byte) (interface{}, error) {
+	data := &model.VoiceState{}
+	if err := data.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// InvokeDecoded implements Decoder.InvokeDecoded
+func (p VoiceStateUpdate) InvokeDecoded(v interface{}) { p(v.(*model.VoiceState)) }
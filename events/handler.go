@@ -10,3 +10,29 @@ type Handler interface {
 	// the socket. It may return an error if unmarshalling fails.
 	Invoke(b []byte) error
 }
+
+// A Decoder is a Handler which can be invoked with an already-decoded
+// payload. Each event is decoded once for all Decoders of the same type,
+// which share the result, so it must not be modified. Wrap a handler with
+// Copy if it needs to modify its payload.
+type Decoder interface {
+	Handler
+	// Decode unmarshals the raw byte payload.
+	Decode(b []byte) (interface{}, error)
+	// InvokeDecoded calls the handler with a payload returned from Decode
+	// on a handler of the same type. The payload must not be modified.
+	InvokeDecoded(v interface{})
+}
+
+// Copy wraps the handler so that it decodes its own copy of each payload,
+// rather than sharing one with other handlers, and so may modify it.
+func Copy(h Handler) Handler { return copied{h} }
+
+// copied hides the Decoder methods of its handler.
+type copied struct{ h Handler }
+
+// Name implements Handler.Name
+func (c copied) Name() string { return c.h.Name() }
+
+// Invoke implements Handler.Invoke
+func (c copied) Invoke(b []byte) error { return c.h.Invoke(b) }
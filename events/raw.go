@@ -0,0 +1,59 @@
+package events
+
+// AnyStr is the name of handlers which are called for every event.
+var AnyStr = "*"
+
+// A NamedHandler is a Handler which is told the name of each event it
+// handles, for handlers which listen to more than one.
+type NamedHandler interface {
+	Handler
+	// InvokeNamed is called instead of Invoke with the event's name and
+	// raw payload.
+	InvokeNamed(name string, b []byte) error
+}
+
+// Any is a handler which is called for every event, including ones which
+// have no generated handler, with the event's name and raw payload. The
+// payload must not be modified.
+type Any func(name string, raw []byte)
+
+var _ NamedHandler = Any(func(name string, raw []byte) {})
+
+// Name implements Handler.Name
+func (p Any) Name() string { return AnyStr }
+
+// Invoke implements Handler.Invoke. It's called with an empty name.
+func (p Any) Invoke(b []byte) error { return p.InvokeNamed("", b) }
+
+// InvokeNamed implements NamedHandler.InvokeNamed
+func (p Any) InvokeNamed(name string, b []byte) error {
+	p(name, b)
+	return nil
+}
+
+// Raw returns a handler for the named event which is called with its raw
+// payload, for events which have no generated handler or to decode them
+// yourself. The payload must not be modified.
+func Raw(name string, fn func(raw []byte)) Handler { return raw{name, fn} }
+
+type raw struct {
+	name string
+	fn   func(raw []byte)
+}
+
+// Name implements Handler.Name
+func (r raw) Name() string { return r.name }
+
+// Invoke implements Handler.Invoke
+func (r raw) Invoke(b []byte) error {
+	r.fn(b)
+	return nil
+}
+
+// An Event is an event received from a Socket's Subscribe channel.
+type Event struct {
+	// Name is the name of the event, such as MESSAGE_CREATE.
+	Name string
+	// Data is the raw payload, which must not be modified.
+	Data []byte
+}
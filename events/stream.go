@@ -0,0 +1,92 @@
+//go:build go1.18
+// +build go1.18
+
+package events
+
+import (
+	"context"
+	"io"
+)
+
+// A Stream decodes events received from a Socket's Subscribe channel into
+// models of type T, such as model.Message.
+type Stream[T any] struct {
+	src    <-chan Event
+	decode func(b []byte) (*T, error)
+}
+
+// NewStream returns a Stream of the events sent down the channel:
+//
+//	msgs, cancel := socket.Subscribe(events.MessageCreateStr, 64)
+//	defer cancel()
+//	stream := events.NewStream[model.Message](msgs)
+func NewStream[T any, PT interface {
+	*T
+	UnmarshalJSON(b []byte) error
+}](src <-chan Event) *Stream[T] {
+	return &Stream[T]{
+		src: src,
+		decode: func(b []byte) (*T, error) {
+			v := PT(new(T))
+			if err := v.UnmarshalJSON(b); err != nil {
+				return nil, err
+			}
+
+			return (*T)(v), nil
+		},
+	}
+}
+
+// Next waits for the next event and returns its decoded model. It returns
+// io.EOF once the subscription is cancelled or the socket is closed, or the
+// context's error if it's done first. If an event fails to decode, its
+// error is returned and the stream may still be read from.
+func (s *Stream[T]) Next(ctx context.Context) (*T, error) {
+	select {
+	case ev, ok := <-s.src:
+		if !ok {
+			return nil, io.EOF
+		}
+
+		return s.decode(ev.Data)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Chan returns a channel of decoded models, for use in select statements.
+// Events which fail to decode are skipped. It starts a goroutine which
+// runs until the subscription is cancelled, the socket is closed, or the
+// context is done, when the channel is closed, so it should be called at
+// most once. Consumers which stop reading early must cancel the context.
+func (s *Stream[T]) Chan(ctx context.Context) <-chan *T {
+	out := make(chan *T)
+	go func() {
+		defer close(out)
+		for {
+			var ev Event
+			select {
+			case next, ok := <-s.src:
+				if !ok {
+					return
+				}
+				ev = next
+			case <-ctx.Done():
+				return
+			}
+
+			v, err := s.decode(ev.Data)
+			if err != nil {
+				continue
+			}
+
+			select {
+			case out <- v:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,84 @@
+//go:build go1.18
+// +build go1.18
+
+package events
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/cord/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamDecodesEvents(t *testing.T) {
+	src := make(chan Event, 3)
+	src <- Event{Name: MessageCreateStr, Data: []byte(`{"id":"1"}`)}
+	src <- Event{Name: MessageCreateStr, Data: []byte(`{"id":`)}
+	src <- Event{Name: MessageCreateStr, Data: []byte(`{"id":"2"}`)}
+	close(src)
+
+	stream := NewStream[model.Message](src)
+	m, err := stream.Next(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "1", m.ID)
+
+	_, err = stream.Next(context.Background())
+	assert.NotNil(t, err)
+
+	m, err = stream.Next(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "2", m.ID)
+
+	_, err = stream.Next(context.Background())
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestStreamNextRespectsContext(t *testing.T) {
+	stream := NewStream[model.Message](make(chan Event))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	_, err := stream.Next(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestStreamChanSkipsUndecodableEvents(t *testing.T) {
+	src := make(chan Event, 2)
+	src <- Event{Name: MessageCreateStr, Data: []byte(`{"id":`)}
+	src <- Event{Name: MessageCreateStr, Data: []byte(`{"id":"1"}`)}
+	close(src)
+
+	var ids []string
+	for m := range NewStream[model.Message](src).Chan(context.Background()) {
+		ids = append(ids, m.ID)
+	}
+	assert.Equal(t, []string{"1"}, ids)
+}
+
+func TestStreamChanStopsWithContext(t *testing.T) {
+	src := make(chan Event, 1)
+	src <- Event{Name: MessageCreateStr, Data: []byte(`{"id":"1"}`)}
+
+	// The consumer leaves without reading, while the goroutine is
+	// waiting to send.
+	ctx, cancel := context.WithCancel(context.Background())
+	out := NewStream[model.Message](src).Chan(ctx)
+	assert.Eventually(t, func() bool { return len(src) == 0 }, time.Second, time.Millisecond)
+	cancel()
+
+	closed := make(chan struct{})
+	go func() {
+		for range out {
+		}
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the goroutine to stop")
+	}
+}
@@ -1,8 +1,11 @@
 package cord
 
 import (
+	"strconv"
 	"testing"
 
+	"github.com/WatchBeam/cord/events"
+	"github.com/WatchBeam/cord/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -61,3 +64,262 @@ func TestHandlerRemoves(t *testing.T) {
 	assert.Nil(t, e.Dispatch("hello", []byte{1, 2, 3}))
 	h.AssertExpectations(t)
 }
+
+func TestMiddlewareWrapsDispatch(t *testing.T) {
+	e := newEmitter()
+	h := &mockHandler{}
+	e.On(h)
+
+	var calls []string
+	e.Use(func(event string, data []byte, next DispatchFunc) error {
+		calls = append(calls, "outer "+event)
+		return next(event, data)
+	})
+	e.Use(func(event string, data []byte, next DispatchFunc) error {
+		calls = append(calls, "inner "+event)
+		if event == "goodbye" {
+			return nil
+		}
+		return next(event, []byte{4, 5, 6})
+	})
+
+	h.On("Invoke", []byte{4, 5, 6}).Return(nil)
+	assert.Nil(t, e.Dispatch("hello", []byte{1, 2, 3}))
+	assert.Nil(t, e.Dispatch("goodbye", []byte{1, 2, 3}))
+	h.AssertExpectations(t)
+	h.AssertNumberOfCalls(t, "Invoke", 1)
+	assert.Equal(t, []string{"outer hello", "inner hello", "outer goodbye", "inner goodbye"}, calls)
+}
+
+func TestMiddlewareSeesHandlerErrors(t *testing.T) {
+	e := newEmitter()
+	h := &mockHandler{}
+	e.On(h)
+
+	var seen error
+	e.Use(func(event string, data []byte, next DispatchFunc) error {
+		seen = next(event, data)
+		return nil
+	})
+
+	h.On("Invoke", []byte{1, 2, 3}).Return(assert.AnError)
+	assert.Nil(t, e.Dispatch("hello", []byte{1, 2, 3}))
+	assert.Equal(t, HandlerErrors{HandlerError{"hello", h, assert.AnError}}, seen)
+}
+
+func TestMiddlewarePanicsAreRecovered(t *testing.T) {
+	e := newEmitter()
+	h := &mockHandler{}
+	e.On(h)
+
+	var seen error
+	e.Use(func(event string, data []byte, next DispatchFunc) error {
+		seen = next(event, data)
+		return nil
+	})
+	e.Use(func(event string, data []byte, next DispatchFunc) error { panic("oh no") })
+
+	assert.Nil(t, e.Dispatch("hello", []byte{1, 2, 3}))
+	h.AssertNotCalled(t, "Invoke", []byte{1, 2, 3})
+
+	perr, ok := seen.(MiddlewarePanicError)
+	if assert.True(t, ok) {
+		assert.Equal(t, "hello", perr.Event)
+		assert.Equal(t, "oh no", perr.Value)
+		assert.Contains(t, string(perr.Stack), "TestMiddlewarePanicsAreRecovered")
+		assert.Equal(t, "cord/websocket: middleware for hello panicked: oh no", perr.Error())
+	}
+}
+
+func TestHandlerPanicsAreRecovered(t *testing.T) {
+	e := newEmitter()
+	called := false
+	e.On(events.Ready(func(r *model.Ready) { panic("oh no") }))
+	e.On(events.Ready(func(r *model.Ready) { called = true }))
+
+	err := e.Dispatch("READY", []byte(`{}`))
+	assert.True(t, called, "expected the remaining handlers to be called")
+
+	errs, _ := err.(HandlerErrors)
+	assert.Len(t, errs, 1)
+	perr, ok := errs[0].(HandlerPanicError)
+	if assert.True(t, ok) {
+		assert.Equal(t, "READY", perr.Event)
+		assert.Equal(t, "oh no", perr.Value)
+		assert.Contains(t, string(perr.Stack), "TestHandlerPanicsAreRecovered")
+		assert.Equal(t, "cord/websocket: events.Ready github.com/WatchBeam/cord.TestHandlerPanicsAreRecovered.func1 "+
+			"handler for READY panicked: oh no", perr.Error())
+	}
+}
+
+func TestHandlerErrorsDontStopSiblings(t *testing.T) {
+	e := newEmitter()
+	first, second, third := &mockHandler{}, &mockHandler{}, &mockHandler{}
+	e.On(first)
+	e.On(second)
+	e.On(third)
+
+	first.On("Invoke", []byte{1, 2, 3}).Return(assert.AnError)
+	second.On("Invoke", []byte{1, 2, 3}).Return(nil)
+	third.On("Invoke", []byte{1, 2, 3}).Return(assert.AnError)
+
+	err := e.Dispatch("hello", []byte{1, 2, 3})
+	first.AssertExpectations(t)
+	second.AssertExpectations(t)
+	third.AssertExpectations(t)
+
+	assert.Equal(t, HandlerErrors{
+		HandlerError{"hello", first, assert.AnError},
+		HandlerError{"hello", third, assert.AnError},
+	}, err)
+	assert.Equal(t, "cord/websocket: 2 handlers failed: "+
+		"cord/websocket: *cord.mockHandler handler for hello failed: "+assert.AnError.Error()+"; "+
+		"cord/websocket: *cord.mockHandler handler for hello failed: "+assert.AnError.Error(), err.Error())
+}
+
+func TestHandlerErrorsNameFuncHandlers(t *testing.T) {
+	e := newEmitter()
+	e.On(events.MessageCreate(func(m *model.Message) {}))
+
+	err := e.Dispatch(events.MessageCreateStr, []byte(`{"id":`))
+	if errs, ok := err.(HandlerErrors); assert.True(t, ok) && assert.Len(t, errs, 1) {
+		assert.Contains(t, errs[0].Error(), "cord/websocket: events.MessageCreate "+
+			"github.com/WatchBeam/cord.TestHandlerErrorsNameFuncHandlers.func1 handler for MESSAGE_CREATE failed: ")
+	}
+}
+
+func TestSharesDecodedPayloads(t *testing.T) {
+	e := newEmitter()
+
+	var shared, copied []*model.Message
+	for i := 0; i < 2; i++ {
+		e.On(events.MessageCreate(func(m *model.Message) { shared = append(shared, m) }))
+		e.On(events.Copy(events.MessageCreate(func(m *model.Message) { copied = append(copied, m) })))
+	}
+
+	assert.Nil(t, e.Dispatch(events.MessageCreateStr, []byte(`{"id":"1"}`)))
+	assert.Len(t, shared, 2)
+	assert.Len(t, copied, 2)
+	assert.True(t, shared[0] == shared[1], "expected handlers to share the payload")
+	assert.False(t, copied[0] == copied[1] || copied[0] == shared[0], "expected copies")
+	assert.Equal(t, "1", copied[1].ID)
+
+	// The shared payload's failure is reported once, and each copy's too.
+	err := e.Dispatch(events.MessageCreateStr, []byte(`{"id":`))
+	assert.Len(t, err, 3)
+}
+
+// guildCreatePayload returns a GUILD_CREATE payload for a guild of the
+// given size.
+func guildCreatePayload(members int) []byte {
+	guild := &model.Guild{ID: "41771983423143937", Name: "benchmark", Large: true}
+	for i := 0; i < 50; i++ {
+		guild.Roles = append(guild.Roles, &model.Role{ID: strconv.Itoa(i), Name: "role", Permissions: 104324161})
+	}
+	for i := 0; i < 100; i++ {
+		guild.Channels = append(guild.Channels, &model.Channel{ID: strconv.Itoa(i), Name: "channel", Topic: "topic"})
+	}
+	for i := 0; i < members; i++ {
+		guild.Members = append(guild.Members, &model.Member{
+			JoinedAt: "2017-01-01T00:00:00.000000+00:00",
+			User:     &model.User{ID: strconv.Itoa(i), Username: "member", Discriminator: "0001"},
+			Roles:    []string{"1", "2", "3"},
+		})
+	}
+
+	b, err := guild.MarshalJSON()
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+func benchmarkGuildCreate(b *testing.B, wrap func(events.Handler) events.Handler) {
+	e := newEmitter()
+	for i := 0; i < 10; i++ {
+		e.On(wrap(events.GuildCreate(func(g *model.Guild) {})))
+	}
+
+	payload := guildCreatePayload(1000)
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := e.Dispatch(events.GuildCreateStr, payload); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGuildCreateShared(b *testing.B) {
+	benchmarkGuildCreate(b, func(h events.Handler) events.Handler { return h })
+}
+
+func BenchmarkGuildCreateCopied(b *testing.B) {
+	benchmarkGuildCreate(b, events.Copy)
+}
+
+func TestAnyAndRawHandlers(t *testing.T) {
+	e := newEmitter()
+
+	var names, once []string
+	var raw []byte
+	e.On(events.Any(func(name string, b []byte) { names = append(names, name) }))
+	e.Once(events.Any(func(name string, b []byte) { once = append(once, name) }))
+	e.On(events.Raw("NEW_EVENT", func(b []byte) { raw = b }))
+
+	assert.Nil(t, e.Dispatch("NEW_EVENT", []byte(`{"new":true}`)))
+	assert.Nil(t, e.Dispatch(events.MessageCreateStr, []byte(`{}`)))
+
+	assert.Equal(t, []string{"NEW_EVENT", events.MessageCreateStr}, names)
+	assert.Equal(t, []string{"NEW_EVENT"}, once)
+	assert.Equal(t, `{"new":true}`, string(raw))
+}
+
+func TestSubscriptionsDetachFuncHandlers(t *testing.T) {
+	e := newEmitter()
+
+	var calls []string
+	handler := events.MessageCreate(func(m *model.Message) { calls = append(calls, "on") })
+	on := e.On(handler)
+	once := e.Once(events.MessageCreate(func(m *model.Message) { calls = append(calls, "once") }))
+	e.On(events.MessageCreate(func(m *model.Message) { calls = append(calls, "kept") }))
+
+	assert.NotPanics(t, func() { e.Off(handler) })
+	on.Unsubscribe()
+	on.Unsubscribe()
+	assert.Nil(t, e.Dispatch(events.MessageCreateStr, []byte(`{}`)))
+	once.Unsubscribe()
+	assert.Nil(t, e.Dispatch(events.MessageCreateStr, []byte(`{}`)))
+
+	assert.Equal(t, []string{"once", "kept", "kept"}, calls)
+}
+
+func TestOffDetachesCopiedHandlers(t *testing.T) {
+	e := newEmitter()
+
+	called := false
+	e.On(events.Copy(events.MessageCreate(func(m *model.Message) { called = true })))
+	assert.NotPanics(t, func() {
+		e.Off(events.Copy(events.MessageCreate(func(m *model.Message) {})))
+	})
+
+	h := &mockHandler{}
+	e.On(events.Copy(h))
+	e.Off(events.Copy(h))
+	assert.Empty(t, e.handlers[h.Name()])
+
+	assert.Nil(t, e.Dispatch(events.MessageCreateStr, []byte(`{}`)))
+	assert.True(t, called, "expected func handlers to stay attached")
+}
+
+func TestOnceUnsubscribedBeforeDispatch(t *testing.T) {
+	e := newEmitter()
+	h := &mockHandler{}
+	e.Once(h).Unsubscribe()
+
+	assert.Nil(t, e.Dispatch("hello", []byte{1, 2, 3}))
+	h.AssertNotCalled(t, "Invoke", []byte{1, 2, 3})
+}
@@ -1,10 +1,18 @@
 package cord
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
+// defaultAPIBase is the base URL of Discord's REST API used by the default
+// gateway retrievers.
+const defaultAPIBase = "https://discordapp.com/api"
+
 // GatewayRetriever calls the Discord API and returns the socket URL to
 // connect to.
 type GatewayRetriever interface {
@@ -12,6 +20,15 @@ type GatewayRetriever interface {
 	Gateway() (url string, err error)
 }
 
+// botGatewayRetriever is a GatewayRetriever which can also report the
+// recommended shard count and session start limits. If the Websocket's
+// retriever implements it, restarts will wait for the session start limit
+// to reset rather than identifying when no sessions remain.
+type botGatewayRetriever interface {
+	GatewayRetriever
+	BotGateway() (*BotGateway, error)
+}
+
 // HTTPGatewayRetriever is an implementation of the GatewayRetriever that
 // looks up the gateway from Discord's REST API.
 type HTTPGatewayRetriever struct {
@@ -39,3 +56,123 @@ func (h HTTPGatewayRetriever) Gateway() (string, error) {
 
 	return data.URL, nil
 }
+
+// BotGatewayRetriever is an implementation of the GatewayRetriever that
+// looks up the gateway from Discord's /gateway/bot endpoint, which also
+// returns the recommended shard count and session start limits.
+type BotGatewayRetriever struct {
+	Client  *http.Client
+	BaseURL string
+	// Token is the bot token, with or without the "Bot " prefix.
+	Token string
+}
+
+// Gateway implements GatewayRetriever.Gateway
+func (b BotGatewayRetriever) Gateway() (string, error) {
+	gw, err := b.BotGateway()
+	if err != nil {
+		return "", err
+	}
+
+	return gw.URL, nil
+}
+
+// BotGateway returns the gateway URL along with the recommended number of
+// shards and the bot's session start limits.
+func (b BotGatewayRetriever) BotGateway() (*BotGateway, error) {
+	req, err := http.NewRequest("GET", b.BaseURL+"/gateway/bot", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	token := b.Token
+	if !strings.HasPrefix(token, "Bot ") {
+		token = "Bot " + token
+	}
+	req.Header.Set("Authorization", token)
+
+	res, err := b.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cord/gateway: unexpected status %d from /gateway/bot: %s",
+			res.StatusCode, body)
+	}
+
+	data := &BotGateway{}
+	if err := data.UnmarshalJSON(body); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// gatewayCache shares one /gateway/bot lookup between the shards of a
+// ShardManager. It counts down the session start limit as shards identify,
+// and only looks the gateway up again once the limit has run out or reset.
+type gatewayCache struct {
+	retriever botGatewayRetriever
+
+	mu sync.Mutex
+	gw *BotGateway
+	// reset is when the cached session start limit resets.
+	reset time.Time
+}
+
+func newGatewayCache(retriever botGatewayRetriever) *gatewayCache {
+	return &gatewayCache{retriever: retriever}
+}
+
+// Gateway implements GatewayRetriever.Gateway
+func (c *gatewayCache) Gateway() (string, error) {
+	gw, err := c.lookup(false)
+	if err != nil {
+		return "", err
+	}
+
+	return gw.URL, nil
+}
+
+// BotGateway implements botGatewayRetriever.BotGateway. It's called before
+// each identify, so it takes one of the remaining session starts.
+func (c *gatewayCache) BotGateway() (*BotGateway, error) { return c.lookup(true) }
+
+// lookup returns a copy of the cached gateway, refreshing it if needed. If
+// start is true, one session start is taken from the cached limit.
+func (c *gatewayCache) lookup(start bool) (*BotGateway, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	stale := c.gw == nil || !now.Before(c.reset)
+	if stale || (start && c.gw.SessionStartLimit.Remaining <= 0) {
+		gw, err := c.retriever.BotGateway()
+		if err != nil {
+			return nil, err
+		}
+
+		c.gw = gw
+		c.reset = now.Add(gw.SessionStartLimit.ResetAfterDuration())
+	}
+
+	gw := *c.gw
+	gw.SessionStartLimit.ResetAfter = int(c.reset.Sub(now) / time.Millisecond)
+	if start {
+		c.gw.SessionStartLimit.Remaining--
+	}
+
+	return &gw, nil
+}
+
+// ResetAfterDuration returns how long until the session start limit resets.
+func (s SessionStartLimit) ResetAfterDuration() time.Duration {
+	return time.Duration(s.ResetAfter) * time.Millisecond
+}
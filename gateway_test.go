@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -53,3 +54,120 @@ func TestGatewayPropogateHTTPError(t *testing.T) {
 	assert.NotNil(t, err)
 	ts.Close()
 }
+
+func TestBotGatewayReadsGood(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/gateway/bot")
+		assert.Equal(t, "Bot tooken", r.Header.Get("Authorization"))
+		fmt.Fprintln(w, `{"url":"wss://gateway.discord.gg","shards":9,"session_start_limit":`+
+			`{"total":1000,"remaining":999,"reset_after":14400000,"max_concurrency":16}}`)
+	}))
+	defer ts.Close()
+
+	gw, err := BotGatewayRetriever{
+		Client:  http.DefaultClient,
+		BaseURL: ts.URL,
+		Token:   "tooken",
+	}.BotGateway()
+
+	assert.Nil(t, err)
+	assert.Equal(t, &BotGateway{
+		URL:    "wss://gateway.discord.gg",
+		Shards: 9,
+		SessionStartLimit: SessionStartLimit{
+			Total:          1000,
+			Remaining:      999,
+			ResetAfter:     14400000,
+			MaxConcurrency: 16,
+		},
+	}, gw)
+	assert.Equal(t, 4*time.Hour, gw.SessionStartLimit.ResetAfterDuration())
+}
+
+func TestBotGatewayErrorsOnBadStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, `{"message": "401: Unauthorized", "code": 0}`)
+	}))
+	defer ts.Close()
+
+	_, err := BotGatewayRetriever{
+		Client:  http.DefaultClient,
+		BaseURL: ts.URL,
+		Token:   "Bot tooken",
+	}.Gateway()
+
+	assert.NotNil(t, err)
+}
+
+type testBotGatewayRetriever struct{ gw BotGateway }
+
+func (t testBotGatewayRetriever) Gateway() (string, error) { return t.gw.URL, nil }
+
+func (t testBotGatewayRetriever) BotGateway() (*BotGateway, error) { return &t.gw, nil }
+
+func TestLookupGatewayWaitsForSessionStartLimit(t *testing.T) {
+	retriever := testBotGatewayRetriever{BotGateway{
+		URL:               "wss://gateway.discord.gg",
+		SessionStartLimit: SessionStartLimit{Total: 1000, ResetAfter: 50},
+	}}
+	ws := newWebsocket("tooken", &WsOptions{Gateway: retriever}, newEmitter(), newErrChannel())
+
+	start := time.Now()
+	gw, err := ws.lookupGateway()
+	assert.Nil(t, err)
+	assert.Equal(t, "wss://gateway.discord.gg", gw)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// Resumes don't count against the limit, so shouldn't wait.
+	sessionID := "asdf"
+	ws.sessionID = unsafe.Pointer(&sessionID)
+	start = time.Now()
+	_, err = ws.lookupGateway()
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) < 50*time.Millisecond)
+}
+
+// countingBotGatewayRetriever counts how often /gateway/bot is looked up.
+type countingBotGatewayRetriever struct {
+	testBotGatewayRetriever
+	calls int
+}
+
+func (c *countingBotGatewayRetriever) BotGateway() (*BotGateway, error) {
+	c.calls++
+	return c.testBotGatewayRetriever.BotGateway()
+}
+
+func TestGatewayCacheSharesLookups(t *testing.T) {
+	retriever := &countingBotGatewayRetriever{testBotGatewayRetriever: testBotGatewayRetriever{BotGateway{
+		URL:               "wss://gateway.discord.gg",
+		Shards:            2,
+		SessionStartLimit: SessionStartLimit{Total: 1000, Remaining: 2, ResetAfter: 3600000},
+	}}}
+	opts := &ShardOptions{Websocket: &WsOptions{Gateway: retriever}}
+	assert.Nil(t, opts.fillDefaults("tooken"))
+	assert.Equal(t, 2, opts.Shards)
+	assert.Equal(t, 1, retriever.calls)
+
+	// Each shard identifying takes a session start from the cached limit.
+	cache := opts.forShard(1).Gateway.(*gatewayCache)
+	for remaining := 2; remaining > 0; remaining-- {
+		gw, err := cache.BotGateway()
+		assert.Nil(t, err)
+		assert.Equal(t, remaining, gw.SessionStartLimit.Remaining)
+		assert.InDelta(t, 3600000, gw.SessionStartLimit.ResetAfter, 1000)
+	}
+	url, err := cache.Gateway()
+	assert.Nil(t, err)
+	assert.Equal(t, "wss://gateway.discord.gg", url)
+	assert.Equal(t, 1, retriever.calls)
+
+	// Once it runs out, the limit is looked up again.
+	_, err = cache.BotGateway()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, retriever.calls)
+
+	assert.Nil(t, opts.fillDefaults("tooken"))
+	assert.Equal(t, cache, opts.Websocket.Gateway)
+}
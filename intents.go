@@ -0,0 +1,61 @@
+package cord
+
+import (
+	"fmt"
+
+	"github.com/WatchBeam/cord/events"
+	"github.com/WatchBeam/cord/model"
+)
+
+// eventIntents maps events to the intents which cause the gateway to send
+// them. Receiving the event requires at least one of the intents to be
+// enabled. Events not in the map are sent regardless of intents.
+var eventIntents = map[string]model.Intents{
+	events.ChannelCreateStr:           model.IntentGuilds,
+	events.ChannelUpdateStr:           model.IntentGuilds,
+	events.ChannelDeleteStr:           model.IntentGuilds,
+	events.GuildCreateStr:             model.IntentGuilds,
+	events.GuildUpdateStr:             model.IntentGuilds,
+	events.GuildDeleteStr:             model.IntentGuilds,
+	events.GuildRoleCreateStr:         model.IntentGuilds,
+	events.GuildRoleUpdateStr:         model.IntentGuilds,
+	events.GuildRoleDeleteStr:         model.IntentGuilds,
+	events.GuildBanAddStr:             model.IntentGuildBans,
+	events.GuildMemberAddStr:          model.IntentGuildMembers,
+	events.GuildMemberUpdateStr:       model.IntentGuildMembers,
+	events.GuildMemberRemoveStr:       model.IntentGuildMembers,
+	events.GuildIntegrationsUpdateStr: model.IntentGuildIntegrations,
+	events.GuildEmojisUpdateStr:       model.IntentGuildEmojis,
+	events.MessageCreateStr:           model.IntentGuildMessages | model.IntentDirectMessages,
+	events.MessageUpdateStr:           model.IntentGuildMessages | model.IntentDirectMessages,
+	events.MessageDeleteStr:           model.IntentGuildMessages | model.IntentDirectMessages,
+	events.PresenceUpdateStr:          model.IntentGuildPresences,
+	events.TypingStartStr:             model.IntentGuildMessageTyping | model.IntentDirectMessageTyping,
+	events.VoiceStateUpdateStr:        model.IntentGuildVoiceStates,
+}
+
+// An IntentWarning is sent when a handler is attached for an event which
+// the gateway will never send, because none of the intents required to
+// receive it were enabled in the handshake.
+type IntentWarning struct {
+	Event    string
+	Required model.Intents
+}
+
+// Error implements error.Error
+func (i IntentWarning) Error() string {
+	return fmt.Sprintf("cord/websocket: handler for %s will never be called, "+
+		"enable one of the intents %s", i.Event, i.Required)
+}
+
+// checkIntents returns an IntentWarning if the handler's event can't be
+// received with the given intents. Intents of zero are treated as the
+// gateway's legacy behavior of sending everything.
+func checkIntents(h events.Handler, intents model.Intents) error {
+	required := eventIntents[h.Name()]
+	if intents == 0 || required == 0 || intents&required != 0 {
+		return nil
+	}
+
+	return IntentWarning{Event: h.Name(), Required: required}
+}
@@ -0,0 +1,42 @@
+package cord
+
+import (
+	"testing"
+
+	"github.com/WatchBeam/cord/events"
+	"github.com/WatchBeam/cord/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntentsHelpers(t *testing.T) {
+	i := model.IntentGuilds.Add(model.IntentGuildMessages | model.IntentGuildPresences)
+	assert.True(t, i.Has(model.IntentGuilds|model.IntentGuildMessages))
+	assert.False(t, i.Has(model.IntentGuildMembers))
+
+	i = i.Remove(model.IntentGuildPresences)
+	assert.False(t, i.Has(model.IntentGuildPresences))
+	assert.Equal(t, "GUILDS|GUILD_MESSAGES", i.String())
+	assert.False(t, model.IntentsUnprivileged.Has(model.IntentMessageContent))
+}
+
+func TestIntentsSerializeInHandshake(t *testing.T) {
+	b, err := model.Handshake{Intents: model.IntentGuilds | model.IntentGuildMessages}.MarshalJSON()
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"intents":513`)
+
+	b, err = model.Handshake{}.MarshalJSON()
+	assert.Nil(t, err)
+	assert.NotContains(t, string(b), `"intents"`)
+}
+
+func TestCheckIntentsWarnsForUnreachableHandlers(t *testing.T) {
+	h := events.MessageCreate(func(m *model.Message) {})
+
+	assert.Nil(t, checkIntents(h, 0))
+	assert.Nil(t, checkIntents(h, model.IntentDirectMessages))
+	assert.Nil(t, checkIntents(events.Ready(func(r *model.Ready) {}), model.IntentGuilds))
+	assert.Equal(t, IntentWarning{
+		Event:    events.MessageCreateStr,
+		Required: model.IntentGuildMessages | model.IntentDirectMessages,
+	}, checkIntents(h, model.IntentGuilds))
+}
@@ -0,0 +1,81 @@
+package model
+
+import "strings"
+
+// Intents is a bitset sent in the Handshake which tells the gateway which
+// groups of events the client wants to receive.
+type Intents uint64
+
+// Gateway intents. See Discord's documentation for the events each of
+// these enables.
+const (
+	IntentGuilds Intents = 1 << iota
+	IntentGuildMembers
+	IntentGuildBans
+	IntentGuildEmojis
+	IntentGuildIntegrations
+	IntentGuildWebhooks
+	IntentGuildInvites
+	IntentGuildVoiceStates
+	IntentGuildPresences
+	IntentGuildMessages
+	IntentGuildMessageReactions
+	IntentGuildMessageTyping
+	IntentDirectMessages
+	IntentDirectMessageReactions
+	IntentDirectMessageTyping
+	IntentMessageContent
+	IntentGuildScheduledEvents
+)
+
+// Intents which must be enabled for the bot in the developer portal before
+// they may be requested.
+const IntentsPrivileged = IntentGuildMembers | IntentGuildPresences | IntentMessageContent
+
+// IntentsAll contains every known intent.
+const IntentsAll = IntentGuildScheduledEvents<<1 - 1
+
+// IntentsUnprivileged contains every intent which can be requested without
+// special approval.
+const IntentsUnprivileged = IntentsAll &^ IntentsPrivileged
+
+var intentNames = []string{
+	"GUILDS",
+	"GUILD_MEMBERS",
+	"GUILD_BANS",
+	"GUILD_EMOJIS",
+	"GUILD_INTEGRATIONS",
+	"GUILD_WEBHOOKS",
+	"GUILD_INVITES",
+	"GUILD_VOICE_STATES",
+	"GUILD_PRESENCES",
+	"GUILD_MESSAGES",
+	"GUILD_MESSAGE_REACTIONS",
+	"GUILD_MESSAGE_TYPING",
+	"DIRECT_MESSAGES",
+	"DIRECT_MESSAGE_REACTIONS",
+	"DIRECT_MESSAGE_TYPING",
+	"MESSAGE_CONTENT",
+	"GUILD_SCHEDULED_EVENTS",
+}
+
+// Has returns true if all the intents in `other` are enabled.
+func (i Intents) Has(other Intents) bool { return i&other == other }
+
+// Add returns the intents with all of `other` enabled.
+func (i Intents) Add(other Intents) Intents { return i | other }
+
+// Remove returns the intents with all of `other` disabled.
+func (i Intents) Remove(other Intents) Intents { return i &^ other }
+
+// String returns the names of the enabled intents separated by pipes.
+func (i Intents) String() string {
+	var names []string
+	for bit, name := range intentNames {
+		if i.Has(1 << uint(bit)) {
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, "|")
+}
@@ -208,6 +208,7 @@ type Event struct {
 type Ready struct {
 	Version           int          `json:"v"`
 	SessionID         string       `json:"session_id"`
+	ResumeGatewayURL  string       `json:"resume_gateway_url"`
 	HeartbeatInterval uint         `json:"heartbeat_interval"`
 	User              *User        `json:"user"`
 	ReadState         []*ReadState `json:"read_state"`
@@ -379,12 +380,19 @@ type Resumed struct {
 	HeartbeatInterval uint `json:"heartbeat_interval"`
 }
 
+// Hello is received immediately after connecting to the gateway.
+type Hello struct {
+	HeartbeatInterval uint `json:"heartbeat_interval"`
+}
+
 // Handshake is sent initially on the first connection to the server.
 type Handshake struct {
 	Token          string              `json:"token"`
 	Properties     HandshakeProperties `json:"properties"`
 	Compress       bool                `json:"compress"`
 	LargeThreshold int                 `json:"large_threshold"`
+	Intents        Intents             `json:"intents,omitempty"`
+	Shard          []int               `json:"shard,omitempty"`
 }
 
 // HandhsakeProperties are contained within the handshake and describe the
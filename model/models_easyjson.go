@@ -118,6 +118,22 @@ func easyjson_d2b7633e_decode_github_com_WatchBeam_cord_model_Handshake(in *jlex
 			out.Compress = bool(in.Bool())
 		case "large_threshold":
 			out.LargeThreshold = int(in.Int())
+		case "intents":
+			out.Intents = Intents(in.Uint64())
+		case "shard":
+			in.Delim('[')
+			if !in.IsDelim(']') {
+				out.Shard = make([]int, 0, 8)
+			} else {
+				out.Shard = nil
+			}
+			for !in.IsDelim(']') {
+				var v61 int
+				v61 = int(in.Int())
+				out.Shard = append(out.Shard, v61)
+				in.WantComma()
+			}
+			in.Delim(']')
 		default:
 			in.SkipRecursive()
 		}
@@ -153,6 +169,29 @@ func easyjson_d2b7633e_encode_github_com_WatchBeam_cord_model_Handshake(out *jwr
 	first = false
 	out.RawString("\"large_threshold\":")
 	out.Int(int(in.LargeThreshold))
+	if in.Intents != 0 {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"intents\":")
+		out.Uint64(uint64(in.Intents))
+	}
+	if len(in.Shard) != 0 {
+		if !first {
+			out.RawByte(',')
+		}
+		first = false
+		out.RawString("\"shard\":")
+		out.RawByte('[')
+		for v62, v63 := range in.Shard {
+			if v62 > 0 {
+				out.RawByte(',')
+			}
+			out.Int(int(v63))
+		}
+		out.RawByte(']')
+	}
 	out.RawByte('}')
 }
 func (v Handshake) MarshalJSON() ([]byte, error) {
@@ -171,6 +210,58 @@ func (v *Handshake) UnmarshalJSON(data []byte) error {
 func (v *Handshake) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson_d2b7633e_decode_github_com_WatchBeam_cord_model_Handshake(l, v)
 }
+func easyjson_d2b7633e_decode_github_com_WatchBeam_cord_model_Hello(in *jlexer.Lexer, out *Hello) {
+	if in.IsNull() {
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeString()
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "heartbeat_interval":
+			out.HeartbeatInterval = uint(in.Uint())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+}
+func easyjson_d2b7633e_encode_github_com_WatchBeam_cord_model_Hello(out *jwriter.Writer, in Hello) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"heartbeat_interval\":")
+	out.Uint(uint(in.HeartbeatInterval))
+	out.RawByte('}')
+}
+func (v Hello) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson_d2b7633e_encode_github_com_WatchBeam_cord_model_Hello(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+func (v Hello) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson_d2b7633e_encode_github_com_WatchBeam_cord_model_Hello(w, v)
+}
+func (v *Hello) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson_d2b7633e_decode_github_com_WatchBeam_cord_model_Hello(&r, v)
+	return r.Error()
+}
+func (v *Hello) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson_d2b7633e_decode_github_com_WatchBeam_cord_model_Hello(l, v)
+}
 func easyjson_d2b7633e_decode_github_com_WatchBeam_cord_model_Resumed(in *jlexer.Lexer, out *Resumed) {
 	if in.IsNull() {
 		in.Skip()
@@ -1967,6 +2058,8 @@ func easyjson_d2b7633e_decode_github_com_WatchBeam_cord_model_Ready(in *jlexer.L
 			out.Version = int(in.Int())
 		case "session_id":
 			out.SessionID = string(in.String())
+		case "resume_gateway_url":
+			out.ResumeGatewayURL = string(in.String())
 		case "heartbeat_interval":
 			out.HeartbeatInterval = uint(in.Uint())
 		case "user":
@@ -2064,6 +2157,12 @@ func easyjson_d2b7633e_encode_github_com_WatchBeam_cord_model_Ready(out *jwriter
 		out.RawByte(',')
 	}
 	first = false
+	out.RawString("\"resume_gateway_url\":")
+	out.String(string(in.ResumeGatewayURL))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
 	out.RawString("\"heartbeat_interval\":")
 	out.Uint(uint(in.HeartbeatInterval))
 	if !first {
@@ -17,6 +17,41 @@ type gatewayResponse struct {
 	URL string `json:"url"`
 }
 
+// BotGateway is returned from /gateway/bot on Discord's API.
+type BotGateway struct {
+	URL               string            `json:"url"`
+	Shards            int               `json:"shards"`
+	SessionStartLimit SessionStartLimit `json:"session_start_limit"`
+}
+
+// SessionStartLimit describes how many more sessions a bot may start before
+// the limit resets.
+type SessionStartLimit struct {
+	Total          int `json:"total"`
+	Remaining      int `json:"remaining"`
+	ResetAfter     int `json:"reset_after"` // in milliseconds
+	MaxConcurrency int `json:"max_concurrency"`
+}
+
+// guildScopedPacket is used to read the guild ID from outgoing packets so
+// that they can be routed to the shard which holds the guild, and the
+// guild and channel IDs from incoming events to pick their DispatchKey.
+// ID is the guild's own ID in events whose payload is a guild.
+type guildScopedPacket struct {
+	GuildID   string `json:"guild_id"`
+	ChannelID string `json:"channel_id"`
+	ID        string `json:"id"`
+}
+
+// Session is the state needed to resume a gateway session. It's persisted
+// by a SessionStore. ResumeURL is the gateway to reconnect to when
+// resuming.
+type Session struct {
+	ID        string `json:"session_id"`
+	Sequence  uint64 `json:"seq"`
+	ResumeURL string `json:"resume_url"`
+}
+
 // An Operation is contained in a Payload and defines what should occur
 // as a result of that payload.
 type Operation uint8
@@ -43,4 +78,9 @@ const (
 	// InvalidSession is an operation used to notify
 	// client they have an invalid session id
 	InvalidSession
+	// Hello is sent by the server immediately after connecting and
+	// contains the heartbeat interval to use
+	Hello
+	// HeartbeatAck is sent by the server to acknowledge a heartbeat
+	HeartbeatAck
 )
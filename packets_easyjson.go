@@ -10,6 +10,286 @@ import (
 
 var _ = json.RawMessage{} // suppress unused package warning
 
+func easyjson_ea487e79_decode_github_com_WatchBeam_cord_Session(in *jlexer.Lexer, out *Session) {
+	if in.IsNull() {
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeString()
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "session_id":
+			out.ID = string(in.String())
+		case "seq":
+			out.Sequence = uint64(in.Uint64())
+		case "resume_url":
+			out.ResumeURL = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+}
+func easyjson_ea487e79_encode_github_com_WatchBeam_cord_Session(out *jwriter.Writer, in Session) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"session_id\":")
+	out.String(string(in.ID))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"seq\":")
+	out.Uint64(uint64(in.Sequence))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"resume_url\":")
+	out.String(string(in.ResumeURL))
+	out.RawByte('}')
+}
+func (v Session) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson_ea487e79_encode_github_com_WatchBeam_cord_Session(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+func (v Session) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson_ea487e79_encode_github_com_WatchBeam_cord_Session(w, v)
+}
+func (v *Session) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson_ea487e79_decode_github_com_WatchBeam_cord_Session(&r, v)
+	return r.Error()
+}
+func (v *Session) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson_ea487e79_decode_github_com_WatchBeam_cord_Session(l, v)
+}
+func easyjson_ea487e79_decode_github_com_WatchBeam_cord_guildScopedPacket(in *jlexer.Lexer, out *guildScopedPacket) {
+	if in.IsNull() {
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeString()
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "guild_id":
+			out.GuildID = string(in.String())
+		case "channel_id":
+			out.ChannelID = string(in.String())
+		case "id":
+			out.ID = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+}
+func easyjson_ea487e79_encode_github_com_WatchBeam_cord_guildScopedPacket(out *jwriter.Writer, in guildScopedPacket) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"guild_id\":")
+	out.String(string(in.GuildID))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"channel_id\":")
+	out.String(string(in.ChannelID))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"id\":")
+	out.String(string(in.ID))
+	out.RawByte('}')
+}
+func (v guildScopedPacket) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson_ea487e79_encode_github_com_WatchBeam_cord_guildScopedPacket(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+func (v guildScopedPacket) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson_ea487e79_encode_github_com_WatchBeam_cord_guildScopedPacket(w, v)
+}
+func (v *guildScopedPacket) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson_ea487e79_decode_github_com_WatchBeam_cord_guildScopedPacket(&r, v)
+	return r.Error()
+}
+func (v *guildScopedPacket) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson_ea487e79_decode_github_com_WatchBeam_cord_guildScopedPacket(l, v)
+}
+func easyjson_ea487e79_decode_github_com_WatchBeam_cord_SessionStartLimit(in *jlexer.Lexer, out *SessionStartLimit) {
+	if in.IsNull() {
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeString()
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "total":
+			out.Total = int(in.Int())
+		case "remaining":
+			out.Remaining = int(in.Int())
+		case "reset_after":
+			out.ResetAfter = int(in.Int())
+		case "max_concurrency":
+			out.MaxConcurrency = int(in.Int())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+}
+func easyjson_ea487e79_encode_github_com_WatchBeam_cord_SessionStartLimit(out *jwriter.Writer, in SessionStartLimit) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"total\":")
+	out.Int(int(in.Total))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"remaining\":")
+	out.Int(int(in.Remaining))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"reset_after\":")
+	out.Int(int(in.ResetAfter))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"max_concurrency\":")
+	out.Int(int(in.MaxConcurrency))
+	out.RawByte('}')
+}
+func (v SessionStartLimit) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson_ea487e79_encode_github_com_WatchBeam_cord_SessionStartLimit(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+func (v SessionStartLimit) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson_ea487e79_encode_github_com_WatchBeam_cord_SessionStartLimit(w, v)
+}
+func (v *SessionStartLimit) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson_ea487e79_decode_github_com_WatchBeam_cord_SessionStartLimit(&r, v)
+	return r.Error()
+}
+func (v *SessionStartLimit) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson_ea487e79_decode_github_com_WatchBeam_cord_SessionStartLimit(l, v)
+}
+func easyjson_ea487e79_decode_github_com_WatchBeam_cord_BotGateway(in *jlexer.Lexer, out *BotGateway) {
+	if in.IsNull() {
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeString()
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "url":
+			out.URL = string(in.String())
+		case "shards":
+			out.Shards = int(in.Int())
+		case "session_start_limit":
+			(out.SessionStartLimit).UnmarshalEasyJSON(in)
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+}
+func easyjson_ea487e79_encode_github_com_WatchBeam_cord_BotGateway(out *jwriter.Writer, in BotGateway) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"url\":")
+	out.String(string(in.URL))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"shards\":")
+	out.Int(int(in.Shards))
+	if !first {
+		out.RawByte(',')
+	}
+	first = false
+	out.RawString("\"session_start_limit\":")
+	(in.SessionStartLimit).MarshalEasyJSON(out)
+	out.RawByte('}')
+}
+func (v BotGateway) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson_ea487e79_encode_github_com_WatchBeam_cord_BotGateway(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+func (v BotGateway) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson_ea487e79_encode_github_com_WatchBeam_cord_BotGateway(w, v)
+}
+func (v *BotGateway) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson_ea487e79_decode_github_com_WatchBeam_cord_BotGateway(&r, v)
+	return r.Error()
+}
+func (v *BotGateway) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson_ea487e79_decode_github_com_WatchBeam_cord_BotGateway(l, v)
+}
 func easyjson_ea487e79_decode_github_com_WatchBeam_cord_gatewayResponse(in *jlexer.Lexer, out *gatewayResponse) {
 	if in.IsNull() {
 		in.Skip()
@@ -1,38 +1,276 @@
 package cord
 
-import "sync"
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
 
 type queuedMessage struct {
 	data   *Payload
 	result chan error
+
+	// claimed is set by whoever first takes responsibility for the
+	// message: either the writer sending it, or the sender abandoning it.
+	// Atomically updated.
+	claimed uint32
+
+	// immediate is set for messages from TrySend, which must not wait.
+	// Their rate limit token is taken when they're queued, and they fail
+	// rather than waiting for room in the queue or for a new connection.
+	immediate bool
+}
+
+func newQueuedMessage(data *Payload) *queuedMessage {
+	return &queuedMessage{data: data, result: make(chan error, 1)}
+}
+
+// claim returns true if the caller is the first to claim the message.
+func (m *queuedMessage) claim() bool {
+	return atomic.CompareAndSwapUint32(&m.claimed, 0, 1)
 }
 
+// isClaimed returns whether the message has been claimed.
+func (m *queuedMessage) isClaimed() bool {
+	return atomic.LoadUint32(&m.claimed) == 1
+}
+
+// A priority determines which queued messages are sent first. Lower values
+// are sent first.
+type priority int
+
+const (
+	// priorityHandshake is for heartbeats and handshakes, which keep the
+	// connection alive.
+	priorityHandshake priority = iota
+	// priorityPresence is for presence and voice state updates, which
+	// users see the effects of immediately.
+	priorityPresence
+	// priorityMembers is for guild member requests.
+	priorityMembers
+	// priorityNormal is for everything else.
+	priorityNormal
+
+	numPriorities
+)
+
+// priorityOf returns the priority messages with the operation are sent
+// with.
+func priorityOf(op Operation) priority {
+	switch op {
+	case Heartbeat, Identify, Resume:
+		return priorityHandshake
+	case StatusUpdate, VoiceStatusUpdate:
+		return priorityPresence
+	case RequestMembers:
+		return priorityMembers
+	default:
+		return priorityNormal
+	}
+}
+
+// An OverflowPolicy determines what happens when a message is sent while
+// the queue of outgoing messages is full.
+type OverflowPolicy uint8
+
+const (
+	// OverflowRejectNewest fails the new message with a QueueFullError.
+	// This is the default.
+	OverflowRejectNewest OverflowPolicy = iota
+	// OverflowDropOldest makes room by failing the oldest message of the
+	// lowest priority in the queue with a QueueFullError.
+	OverflowDropOldest
+	// OverflowBlock makes the sender wait until there's room, or until
+	// its context is done.
+	OverflowBlock
+)
+
+// A QueueFullError is returned from Send when the message was rejected or
+// dropped because the queue of outgoing messages was full.
+type QueueFullError struct{ Max int }
+
+// Error implements error.Error
+func (q QueueFullError) Error() string {
+	return fmt.Sprintf("cord/websocket: send queue is full (%d messages)", q.Max)
+}
+
+// queue holds messages waiting to be sent. Messages are sent in order of
+// priority, and in the order they were pushed within each priority.
 type queue struct {
-	mu     *sync.Cond
-	forks  []*queue
-	items  []*queuedMessage
+	mu    sync.Mutex
+	lanes [numPriorities][]*queuedMessage
+	size  int
+
+	// max is the number of messages the queue may hold, or zero if it's
+	// unbounded. overflow determines what happens when it's exceeded.
+	max      int
+	overflow OverflowPolicy
+	// space is closed and replaced whenever messages leave the queue, to
+	// wake senders blocked waiting for room. drained, if set, is closed
+	// once the queue is empty.
+	space   chan struct{}
+	drained chan struct{}
+
+	// next is set once the queue's items have been moved to another
+	// queue. Future messages are forwarded there.
+	next *queue
+
+	notify chan struct{}
 	closer chan struct{}
+	// failed is set once the queue will no longer be sent from; messages
+	// pushed afterwards fail immediately with it.
+	failed error
 }
 
-func newQueue() *queue {
+func newQueue(max int, overflow OverflowPolicy) *queue {
 	return &queue{
-		mu:     sync.NewCond(&sync.Mutex{}),
-		closer: make(chan struct{}),
+		max:      max,
+		overflow: overflow,
+		space:    make(chan struct{}),
+		notify:   make(chan struct{}, 1),
+		closer:   make(chan struct{}),
+	}
+}
+
+// Push appends a new item to the end of its priority's lane. If the
+// message can't be queued, its result is set to the reason why. Push only
+// blocks under OverflowBlock, until there's room or the context is done,
+// and never for immediate messages.
+func (q *queue) Push(ctx context.Context, msg *queuedMessage) {
+	for {
+		q.mu.Lock()
+
+		if q.next != nil && msg.immediate {
+			q.finish(msg, NotConnectedError{StateConnecting})
+			q.mu.Unlock()
+			return
+		} else if q.next != nil {
+			next := q.next
+			q.mu.Unlock()
+			next.Push(ctx, msg)
+			return
+		}
+
+		if q.failed != nil {
+			q.finish(msg, q.failed)
+			q.mu.Unlock()
+			return
+		}
+
+		if q.max > 0 && q.size >= q.max {
+			switch {
+			case q.overflow == OverflowBlock && !msg.immediate:
+				space := q.space
+				q.mu.Unlock()
+
+				select {
+				case <-space:
+					continue
+				case <-ctx.Done():
+					q.finish(msg, ctx.Err())
+					return
+				}
+
+			case q.overflow == OverflowDropOldest:
+				if !q.dropOldest() {
+					q.finish(msg, QueueFullError{q.max})
+					q.mu.Unlock()
+					return
+				}
+
+			default:
+				q.finish(msg, QueueFullError{q.max})
+				q.mu.Unlock()
+				return
+			}
+		}
+
+		q.append(msg)
+		q.mu.Unlock()
+		return
+	}
+}
+
+// append adds the message to its lane and wakes up the reader. The lock
+// must be held.
+func (q *queue) append(msg *queuedMessage) {
+	lane := priorityOf(msg.data.Operation)
+	q.lanes[lane] = append(q.lanes[lane], msg)
+	q.size++
+	q.signal()
+}
+
+// finish sets the result of a message which won't be sent, unless someone
+// else has claimed it already.
+func (q *queue) finish(msg *queuedMessage, err error) {
+	if msg.claim() {
+		msg.result <- err
 	}
 }
 
-// Push appends a new item to the queue. Handshakes packets, however, are
-// always prepended.
-func (q *queue) Push(msg *queuedMessage) {
-	q.mu.L.Lock()
-	defer q.mu.L.Unlock()
+// dropOldest fails the oldest message in the lowest-priority lane with a
+// QueueFullError. It returns false if the queue is empty. The lock must be
+// held.
+func (q *queue) dropOldest() bool {
+	for lane := len(q.lanes) - 1; lane >= 0; lane-- {
+		if items := q.lanes[lane]; len(items) > 0 {
+			q.finish(items[0], QueueFullError{q.max})
+			q.removeAt(priority(lane), 0)
+			return true
+		}
+	}
+
+	return false
+}
 
-	for _, fork := range q.forks {
-		fork.Push(msg)
+// removeAt removes the message at the index in the lane. The lock must be
+// held.
+func (q *queue) removeAt(lane priority, i int) {
+	items := q.lanes[lane]
+	copy(items[i:], items[i+1:])
+	items[len(items)-1] = nil
+	q.lanes[lane] = items[:len(items)-1]
+	q.size--
+	q.shrunk()
+}
+
+// shrunk wakes up anyone waiting for messages to leave the queue. The lock
+// must be held.
+func (q *queue) shrunk() {
+	close(q.space)
+	q.space = make(chan struct{})
+
+	if q.size == 0 && q.drained != nil {
+		close(q.drained)
+		q.drained = nil
 	}
+}
 
-	q.items = append(q.items, msg)
-	q.mu.Broadcast()
+// Drained returns a channel which is closed once the queue is empty.
+func (q *queue) Drained() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.drained == nil {
+		q.drained = make(chan struct{})
+		if q.size == 0 {
+			close(q.drained)
+			ch := q.drained
+			q.drained = nil
+			return ch
+		}
+	}
+
+	return q.drained
+}
+
+// signal wakes up the reader, if it's not already awake.
+func (q *queue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
 }
 
 // Close signals that no further messages may be expected on this queue.
@@ -40,43 +278,114 @@ func (q *queue) Close() {
 	close(q.closer)
 }
 
-// Poll returns a channel that blocks until a message is available on
-// the queue closed.
-func (q *queue) Poll() <-chan *queuedMessage {
-	ch := make(chan *queuedMessage)
-	go func() {
-		q.mu.L.Lock()
-		defer q.mu.L.Unlock()
-		defer close(ch)
+// Ready returns a channel which is signaled when items are pushed to the
+// queue.
+func (q *queue) Ready() <-chan struct{} { return q.notify }
 
-		for len(q.items) == 0 {
-			select {
-			case <-q.closer:
-				return
-			default:
+// Done returns a channel which is closed when the queue is closed.
+func (q *queue) Done() <-chan struct{} { return q.closer }
+
+// Pop removes, claims and returns the first message, by priority, for
+// which allow returns true, or returns nil if there is none. Messages
+// which were already claimed are discarded.
+func (q *queue) Pop(allow func(msg *queuedMessage) bool) *queuedMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for lane := range q.lanes {
+		for i := 0; i < len(q.lanes[lane]); i++ {
+			msg := q.lanes[lane][i]
+			if !msg.isClaimed() && !allow(msg) {
+				continue
+			}
+
+			q.removeAt(priority(lane), i)
+			i--
+
+			if msg.claim() {
+				return msg
 			}
+		}
+	}
+
+	return nil
+}
 
-			q.mu.Wait()
+// Remove removes the message from the queue, or from the queue it was
+// moved to if this one was forked.
+func (q *queue) Remove(msg *queuedMessage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.next != nil {
+		q.next.Remove(msg)
+		return
+	}
+
+	lane := priorityOf(msg.data.Operation)
+	for i, item := range q.lanes[lane] {
+		if item == msg {
+			q.removeAt(lane, i)
+			return
 		}
+	}
+}
 
-		head := q.items[0]
-		q.items = q.items[1:]
-		ch <- head
-	}()
+// Fail fails every message in the queue with the error, along with any
+// pushed in the future. It's called when the queue will never be sent
+// from, such as after the socket is closed.
+func (q *queue) Fail(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 
-	return ch
+	q.failed = err
+	for lane, items := range q.lanes {
+		for _, msg := range items {
+			q.finish(msg, err)
+		}
+		q.lanes[lane] = nil
+	}
+	q.size = 0
+	q.shrunk()
+}
+
+// Len returns the number of messages in the queue.
+func (q *queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.size
 }
 
-// Fork creates a new queue that inherits all current *and future* items
-// from this queue.
-func (q *queue) Fork() *queue {
-	q.mu.L.Lock()
-	defer q.mu.L.Unlock()
+// MoveTo moves all current *and future* items from this queue into the
+// other one. The moved items are placed ahead of any already in it.
+// Immediate messages aren't moved, and fail with a NotConnectedError.
+func (q *queue) MoveTo(to *queue) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	to.mu.Lock()
+	defer to.mu.Unlock()
+
+	for lane, items := range q.lanes {
+		var moved []*queuedMessage
+		for _, msg := range items {
+			if msg.immediate {
+				q.finish(msg, NotConnectedError{StateConnecting})
+			} else {
+				moved = append(moved, msg)
+			}
+		}
+		to.lanes[lane] = append(moved, to.lanes[lane]...)
+		to.size += len(moved)
+	}
+	if to.size > 0 {
+		to.signal()
+	}
 
-	fork := newQueue()
-	fork.items = make([]*queuedMessage, len(q.items))
-	copy(fork.items, q.items)
-	q.forks = append(q.forks, fork)
+	q.lanes = [numPriorities][]*queuedMessage{}
+	q.size = 0
+	q.next = to
 
-	return fork
+	// Wake anyone waiting for room here, so they push to the new queue.
+	q.shrunk()
 }
@@ -0,0 +1,167 @@
+package cord
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func queuedOp(op Operation) *queuedMessage {
+	return newQueuedMessage(&Payload{Operation: op})
+}
+
+func allowAll(msg *queuedMessage) bool { return true }
+
+func TestQueuePopsFirstAllowedMessage(t *testing.T) {
+	q := newQueue(0, OverflowRejectNewest)
+	status := queuedOp(StatusUpdate)
+	members := queuedOp(RequestMembers)
+	q.Push(context.Background(), status)
+	q.Push(context.Background(), members)
+
+	select {
+	case <-q.Ready():
+	default:
+		t.Fatal("expected queue to be ready")
+	}
+
+	msg := q.Pop(func(msg *queuedMessage) bool { return msg.data.Operation != StatusUpdate })
+	assert.Equal(t, members, msg)
+	assert.Equal(t, 1, q.Len())
+	assert.Nil(t, q.Pop(func(msg *queuedMessage) bool { return false }))
+	assert.Equal(t, status, q.Pop(allowAll))
+}
+
+func TestQueuePopsByPriority(t *testing.T) {
+	q := newQueue(0, OverflowRejectNewest)
+	other := queuedOp(Dispatch)
+	members := queuedOp(RequestMembers)
+	voice := queuedOp(VoiceStatusUpdate)
+	status := queuedOp(StatusUpdate)
+	heartbeat := queuedOp(Heartbeat)
+	for _, msg := range []*queuedMessage{other, members, voice, status, heartbeat} {
+		q.Push(context.Background(), msg)
+	}
+
+	for _, expected := range []*queuedMessage{heartbeat, voice, status, members, other} {
+		assert.Equal(t, expected, q.Pop(allowAll))
+	}
+	assert.Nil(t, q.Pop(allowAll))
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestQueueMovesMessages(t *testing.T) {
+	q := newQueue(0, OverflowRejectNewest)
+	older := queuedOp(RequestMembers)
+	q.Push(context.Background(), older)
+
+	next := newQueue(0, OverflowRejectNewest)
+	newer := queuedOp(RequestMembers)
+	next.Push(context.Background(), newer)
+	q.MoveTo(next)
+	assert.Equal(t, 0, q.Len())
+	assert.Equal(t, 2, next.Len())
+
+	// Messages pushed or removed on the old queue go to the new one.
+	forwarded := queuedOp(RequestMembers)
+	q.Push(context.Background(), forwarded)
+	q.Remove(newer)
+
+	assert.Equal(t, older, next.Pop(allowAll))
+	assert.Equal(t, forwarded, next.Pop(allowAll))
+	assert.Nil(t, next.Pop(allowAll))
+}
+
+func TestQueueFailsMessages(t *testing.T) {
+	q := newQueue(0, OverflowRejectNewest)
+	queued := queuedOp(RequestMembers)
+	q.Push(context.Background(), queued)
+	q.Fail(ErrClosed)
+	assert.Equal(t, ErrClosed, <-queued.result)
+
+	later := queuedOp(RequestMembers)
+	q.Push(context.Background(), later)
+	assert.Equal(t, ErrClosed, <-later.result)
+	assert.Equal(t, 0, q.Len())
+}
+
+func TestQueueRejectsNewestWhenFull(t *testing.T) {
+	q := newQueue(1, OverflowRejectNewest)
+	first, second := queuedOp(RequestMembers), queuedOp(RequestMembers)
+	q.Push(context.Background(), first)
+	q.Push(context.Background(), second)
+
+	assert.Equal(t, QueueFullError{1}, <-second.result)
+	assert.Equal(t, first, q.Pop(allowAll))
+}
+
+func TestQueueDropsOldestWhenFull(t *testing.T) {
+	q := newQueue(2, OverflowDropOldest)
+	status, members := queuedOp(StatusUpdate), queuedOp(RequestMembers)
+	q.Push(context.Background(), status)
+	q.Push(context.Background(), members)
+
+	newest := queuedOp(StatusUpdate)
+	q.Push(context.Background(), newest)
+
+	assert.Equal(t, QueueFullError{2}, <-members.result)
+	assert.Equal(t, status, q.Pop(allowAll))
+	assert.Equal(t, newest, q.Pop(allowAll))
+}
+
+func TestQueueFailsImmediateMessagesRatherThanWaiting(t *testing.T) {
+	q := newQueue(1, OverflowBlock)
+	q.Push(context.Background(), queuedOp(RequestMembers))
+
+	full := queuedOp(RequestMembers)
+	full.immediate = true
+	q.Push(context.Background(), full)
+	assert.Equal(t, QueueFullError{1}, <-full.result)
+
+	q = newQueue(0, OverflowBlock)
+	moved, stranded := queuedOp(RequestMembers), queuedOp(RequestMembers)
+	stranded.immediate = true
+	q.Push(context.Background(), moved)
+	q.Push(context.Background(), stranded)
+	next := newQueue(0, OverflowBlock)
+	q.MoveTo(next)
+	assert.Equal(t, NotConnectedError{StateConnecting}, <-stranded.result)
+	assert.Equal(t, 1, next.Len())
+
+	late := queuedOp(RequestMembers)
+	late.immediate = true
+	q.Push(context.Background(), late)
+	assert.Equal(t, NotConnectedError{StateConnecting}, <-late.result)
+	assert.Equal(t, moved, next.Pop(allowAll))
+}
+
+func TestQueueBlocksWhenFull(t *testing.T) {
+	q := newQueue(1, OverflowBlock)
+	first, second := queuedOp(RequestMembers), queuedOp(RequestMembers)
+	q.Push(context.Background(), first)
+
+	pushed := make(chan struct{})
+	go func() {
+		q.Push(context.Background(), second)
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatal("expected push to block")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	assert.Equal(t, first, q.Pop(allowAll))
+	<-pushed
+	assert.Equal(t, second, q.Pop(allowAll))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	third, fourth := queuedOp(RequestMembers), queuedOp(RequestMembers)
+	q.Push(ctx, third)
+	q.Push(ctx, fourth)
+	assert.Equal(t, context.DeadlineExceeded, <-fourth.result)
+}
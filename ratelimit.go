@@ -0,0 +1,195 @@
+package cord
+
+import (
+	"sync"
+	"time"
+)
+
+// A rateLimit allows Count commands to be sent within each Interval.
+type rateLimit struct {
+	Count    int
+	Interval time.Duration
+}
+
+var (
+	// commandLimit is the limit on all commands sent on a connection.
+	commandLimit = rateLimit{Count: 120, Interval: time.Minute}
+	// statusLimit is the stricter limit on StatusUpdate commands.
+	statusLimit = rateLimit{Count: 5, Interval: time.Minute}
+	// heartbeatReserve is the number of commands in each interval which
+	// are kept back for heartbeats and handshakes, so that a burst of
+	// other commands can't starve them.
+	heartbeatReserve = 3
+)
+
+// bucket is a token bucket which is refilled at the end of each interval.
+// The interval starts when the first token is taken.
+type bucket struct {
+	limit  rateLimit
+	tokens int
+	reset  time.Time
+}
+
+func newBucket(limit rateLimit) *bucket {
+	return &bucket{limit: limit, tokens: limit.Count}
+}
+
+// refill adds a full interval's tokens to the bucket if its interval has
+// passed. Tokens taken from an empty bucket are paid back first, in which
+// case the next interval starts right away.
+func (b *bucket) refill(now time.Time) {
+	if b.reset.IsZero() || now.Before(b.reset) {
+		return
+	}
+
+	b.tokens += b.limit.Count
+	if b.tokens > b.limit.Count {
+		b.tokens = b.limit.Count
+	}
+
+	b.reset = time.Time{}
+	if b.tokens < b.limit.Count {
+		b.reset = now.Add(b.limit.Interval)
+	}
+}
+
+// available returns whether a token can be taken while leaving the given
+// number behind.
+func (b *bucket) available(reserve int) bool {
+	return b.tokens > reserve
+}
+
+// take removes a token from the bucket, starting the interval if needed.
+// Tokens may be taken from an empty bucket; those sends count against the
+// next interval.
+func (b *bucket) take(now time.Time) {
+	if b.reset.IsZero() {
+		b.reset = now.Add(b.limit.Interval)
+	}
+	b.tokens--
+}
+
+// limiter enforces Discord's gateway rate limits on a single connection.
+type limiter struct {
+	mu       sync.Mutex
+	commands *bucket
+	status   *bucket
+}
+
+func newLimiter() *limiter {
+	return &limiter{
+		commands: newBucket(commandLimit),
+		status:   newBucket(statusLimit),
+	}
+}
+
+// Allow takes a token for the operation and returns true if it may be sent
+// now, or returns false if it must wait for a bucket to refill. Heartbeats
+// and handshakes may use the reserve.
+func (l *limiter) Allow(op Operation) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.commands.refill(now)
+	l.status.refill(now)
+
+	reserve := heartbeatReserve
+	if priorityOf(op) == priorityHandshake {
+		reserve = 0
+	}
+
+	if !l.commands.available(reserve) {
+		return false
+	}
+	if op == StatusUpdate && !l.status.available(0) {
+		return false
+	}
+
+	l.commands.take(now)
+	if op == StatusUpdate {
+		l.status.take(now)
+	}
+
+	return true
+}
+
+// Force takes a token for a heartbeat or handshake, which are sent even if
+// only the reserve remains.
+func (l *limiter) Force() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.commands.refill(now)
+	l.commands.take(now)
+}
+
+// Delay returns how long until a bucket which is holding back commands is
+// refilled.
+func (l *limiter) Delay() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var reset time.Time
+	for _, b := range []*bucket{l.commands, l.status} {
+		if b.reset.IsZero() {
+			continue
+		}
+		if reset.IsZero() || b.reset.Before(reset) {
+			reset = b.reset
+		}
+	}
+
+	if reset.IsZero() {
+		return 0
+	}
+
+	return reset.Sub(time.Now())
+}
+
+// Budget returns the commands remaining in each bucket.
+func (l *limiter) Budget() Budget {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.commands.refill(now)
+	l.status.refill(now)
+
+	budget := Budget{
+		Commands:      l.commands.tokens - heartbeatReserve,
+		StatusUpdates: l.status.tokens,
+		Reset:         l.commands.reset,
+	}
+	if budget.Commands < 0 {
+		budget.Commands = 0
+	}
+
+	return budget
+}
+
+// Budget describes how many more commands may be sent before the gateway's
+// rate limits are reached. Sends beyond the budget are queued until the
+// limits reset.
+type Budget struct {
+	// Commands is the number of commands which may be sent right away,
+	// excluding those held back for heartbeats.
+	Commands int
+	// StatusUpdates is the number of StatusUpdate commands which may be
+	// sent right away. These also count towards Commands.
+	StatusUpdates int
+	// Reset is when the command limit resets. It's zero if no commands
+	// have been sent in the current interval.
+	Reset time.Time
+	// Queued is the number of sends waiting for budget or a connection.
+	Queued int
+}
+
+// fullBudget is the budget of a connection that hasn't sent anything.
+func fullBudget() Budget {
+	return Budget{
+		Commands:      commandLimit.Count - heartbeatReserve,
+		StatusUpdates: statusLimit.Count,
+	}
+}
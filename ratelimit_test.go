@@ -0,0 +1,72 @@
+package cord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterKeepsReserveForHeartbeats(t *testing.T) {
+	l := &limiter{
+		commands: newBucket(rateLimit{Count: heartbeatReserve + 2, Interval: time.Minute}),
+		status:   newBucket(statusLimit),
+	}
+
+	assert.True(t, l.Allow(RequestMembers))
+	assert.True(t, l.Allow(RequestMembers))
+	assert.False(t, l.Allow(RequestMembers))
+	assert.Equal(t, 0, l.Budget().Commands)
+
+	l.Force()
+	assert.Equal(t, heartbeatReserve-1, l.commands.tokens)
+	assert.InDelta(t, time.Minute, l.Delay(), float64(time.Second))
+}
+
+func TestLimiterLimitsStatusUpdatesSeparately(t *testing.T) {
+	l := &limiter{
+		commands: newBucket(commandLimit),
+		status:   newBucket(rateLimit{Count: 1, Interval: time.Minute}),
+	}
+
+	assert.True(t, l.Allow(StatusUpdate))
+	assert.False(t, l.Allow(StatusUpdate))
+	assert.True(t, l.Allow(RequestMembers))
+
+	budget := l.Budget()
+	assert.Equal(t, 0, budget.StatusUpdates)
+	assert.Equal(t, commandLimit.Count-heartbeatReserve-2, budget.Commands)
+}
+
+func TestLimiterRefillsAfterInterval(t *testing.T) {
+	l := &limiter{
+		commands: newBucket(rateLimit{Count: heartbeatReserve + 1, Interval: 20 * time.Millisecond}),
+		status:   newBucket(statusLimit),
+	}
+
+	assert.True(t, l.Allow(Dispatch))
+	assert.False(t, l.Allow(Dispatch))
+	time.Sleep(l.Delay())
+	assert.True(t, l.Allow(Dispatch))
+}
+
+func TestLimiterCarriesForcedSendsIntoNextInterval(t *testing.T) {
+	l := &limiter{
+		commands: newBucket(rateLimit{Count: heartbeatReserve + 1, Interval: 20 * time.Millisecond}),
+		status:   newBucket(statusLimit),
+	}
+
+	assert.True(t, l.Allow(Dispatch))
+	for i := 0; i < heartbeatReserve+1; i++ {
+		l.Force()
+	}
+	assert.Equal(t, -1, l.commands.tokens)
+
+	time.Sleep(l.Delay())
+	assert.False(t, l.Allow(Dispatch))
+	assert.Equal(t, heartbeatReserve, l.commands.tokens)
+	assert.InDelta(t, 20*time.Millisecond, l.Delay(), float64(10*time.Millisecond))
+
+	time.Sleep(l.Delay())
+	assert.True(t, l.Allow(Dispatch))
+}
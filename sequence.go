@@ -0,0 +1,85 @@
+package cord
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// A SequenceGapError is sent when the gateway skips sequence numbers,
+// which means events were missed and caches built from them may be stale.
+type SequenceGapError struct {
+	// Expected is the sequence number we were waiting for.
+	Expected uint64
+	// Got is the sequence number we received instead.
+	Got uint64
+}
+
+// Missed returns how many events were skipped.
+func (s SequenceGapError) Missed() uint64 { return s.Got - s.Expected }
+
+// Error implements error.Error
+func (s SequenceGapError) Error() string {
+	return fmt.Sprintf("cord/websocket: expected sequence %d, got %d (%d events missed)",
+		s.Expected, s.Got, s.Missed())
+}
+
+// SequenceStats counts irregularities in the sequence numbers of events
+// received from the gateway.
+type SequenceStats struct {
+	// Gaps is the number of times sequence numbers were skipped.
+	Gaps uint64
+	// Missed is the total number of events skipped.
+	Missed uint64
+	// Duplicates is the number of events dropped because they had
+	// already been received, as can happen when they're replayed after
+	// resuming.
+	Duplicates uint64
+}
+
+// add returns the sum of both stats.
+func (s SequenceStats) add(o SequenceStats) SequenceStats {
+	return SequenceStats{
+		Gaps:       s.Gaps + o.Gaps,
+		Missed:     s.Missed + o.Missed,
+		Duplicates: s.Duplicates + o.Duplicates,
+	}
+}
+
+// sequence records the sequence number of a dispatch. It returns false if
+// the dispatch is a duplicate which should be dropped, and sends a
+// SequenceGapError if dispatches were missed. Dispatches without a
+// sequence number are let through unchecked.
+func (w *Websocket) sequence(seq uint64) bool {
+	if seq == 0 {
+		return true
+	}
+
+	for {
+		last := atomic.LoadUint64(&w.lastSeq)
+		if last != 0 && seq <= last {
+			atomic.AddUint64(&w.seqStats.Duplicates, 1)
+			return false
+		}
+
+		if !atomic.CompareAndSwapUint64(&w.lastSeq, last, seq) {
+			continue
+		}
+
+		if last != 0 && seq > last+1 {
+			atomic.AddUint64(&w.seqStats.Gaps, 1)
+			atomic.AddUint64(&w.seqStats.Missed, seq-last-1)
+			go w.sendErr(SequenceGapError{Expected: last + 1, Got: seq})
+		}
+
+		return true
+	}
+}
+
+// SequenceStats implements Socket.SequenceStats
+func (w *Websocket) SequenceStats() SequenceStats {
+	return SequenceStats{
+		Gaps:       atomic.LoadUint64(&w.seqStats.Gaps),
+		Missed:     atomic.LoadUint64(&w.seqStats.Missed),
+		Duplicates: atomic.LoadUint64(&w.seqStats.Duplicates),
+	}
+}
@@ -0,0 +1,25 @@
+package cord
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequenceDetectsGapsAndDuplicates(t *testing.T) {
+	ws := newDisconnectedWebsocket()
+
+	assert.True(t, ws.sequence(1))
+	assert.True(t, ws.sequence(2))
+	assert.False(t, ws.sequence(2))
+	assert.False(t, ws.sequence(1))
+	assert.True(t, ws.sequence(0))
+
+	assert.True(t, ws.sequence(5))
+	err := <-ws.Errs()
+	assert.Equal(t, SequenceGapError{Expected: 3, Got: 5}, err)
+	assert.Equal(t, "cord/websocket: expected sequence 3, got 5 (2 events missed)", err.Error())
+
+	assert.Equal(t, uint64(5), ws.lastSeq)
+	assert.Equal(t, SequenceStats{Gaps: 1, Missed: 2, Duplicates: 2}, ws.SequenceStats())
+}
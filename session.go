@@ -0,0 +1,187 @@
+package cord
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// A SessionStore persists the gateway session, so that a new process can
+// resume the session of the one before it rather than identifying and
+// missing the events sent in between.
+type SessionStore interface {
+	// Load returns the stored session, or nil if there is none.
+	Load() (*Session, error)
+	// Save stores the session. It's called with nil when the session is
+	// invalidated and should be forgotten.
+	Save(session *Session) error
+}
+
+// FileSessionStore is a SessionStore which keeps the session in a file.
+type FileSessionStore struct {
+	Path string
+}
+
+var _ SessionStore = FileSessionStore{}
+
+// Load implements SessionStore.Load
+func (f FileSessionStore) Load() (*Session, error) {
+	b, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	session := &Session{}
+	if err := session.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// Save implements SessionStore.Save. The file is replaced atomically, so a
+// crash while saving leaves the previous session intact.
+func (f FileSessionStore) Save(session *Session) error {
+	if session == nil {
+		if err := os.Remove(f.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	b, err := session.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.Path), filepath.Base(f.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// sessionSaver writes sessions to a SessionStore from a background
+// goroutine, so that a slow store doesn't hold up reading from the socket.
+// Only the latest session is kept, so saves coalesce while the store is
+// busy.
+type sessionSaver struct {
+	store SessionStore
+	onErr func(err error)
+
+	mu      sync.Mutex
+	pending *Session
+	dirty   bool
+	lastErr string
+
+	start    sync.Once
+	started  bool
+	notify   chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+func newSessionSaver(store SessionStore, onErr func(err error)) *sessionSaver {
+	return &sessionSaver{
+		store:   store,
+		onErr:   onErr,
+		notify:  make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Save queues the session to be stored, replacing any session which is
+// still waiting. Sessions saved after Close are dropped.
+func (s *sessionSaver) Save(session *Session) {
+	s.mu.Lock()
+	s.pending = session
+	s.dirty = true
+	s.start.Do(func() {
+		s.started = true
+		go s.run()
+	})
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Close writes the session which is waiting, if any, and stops the saver.
+// It's safe to call more than once, and on a nil saver.
+func (s *sessionSaver) Close() {
+	if s == nil {
+		return
+	}
+
+	s.stopOnce.Do(func() { close(s.stop) })
+
+	s.mu.Lock()
+	started := s.started
+	s.start.Do(func() {}) // Don't start after we've stopped.
+	s.mu.Unlock()
+
+	if started {
+		<-s.stopped
+	}
+}
+
+func (s *sessionSaver) run() {
+	defer close(s.stopped)
+
+	for {
+		select {
+		case <-s.notify:
+			s.flush()
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+// flush writes the waiting session, if any. Errors are reported, unless
+// they're the same as the last one.
+func (s *sessionSaver) flush() {
+	s.mu.Lock()
+	session, dirty := s.pending, s.dirty
+	s.pending, s.dirty = nil, false
+	s.mu.Unlock()
+
+	if !dirty {
+		return
+	}
+
+	err := s.store.Save(session)
+
+	s.mu.Lock()
+	repeated := err != nil && err.Error() == s.lastErr
+	s.lastErr = ""
+	if err != nil {
+		s.lastErr = err.Error()
+	}
+	s.mu.Unlock()
+
+	if err != nil && !repeated {
+		s.onErr(err)
+	}
+}
@@ -0,0 +1,121 @@
+package cord
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSessionStoreSavesAndLoads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cord")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	store := FileSessionStore{Path: filepath.Join(dir, "session.json")}
+
+	session, err := store.Load()
+	assert.Nil(t, err)
+	assert.Nil(t, session)
+
+	saved := &Session{ID: "asdf", Sequence: 42, ResumeURL: "wss://gateway.discord.gg"}
+	assert.Nil(t, store.Save(saved))
+	session, err = store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, saved, session)
+
+	assert.Nil(t, store.Save(nil))
+	session, err = store.Load()
+	assert.Nil(t, err)
+	assert.Nil(t, session)
+	assert.Nil(t, store.Save(nil))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 0)
+}
+
+// blockingSessionStore is a memorySessionStore which waits for each save
+// to be released.
+type blockingSessionStore struct {
+	memorySessionStore
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingSessionStore) Save(s *Session) error {
+	b.saving <- struct{}{}
+	<-b.release
+	return b.memorySessionStore.Save(s)
+}
+
+func TestSessionSaverCoalescesSaves(t *testing.T) {
+	store := &blockingSessionStore{saving: make(chan struct{}), release: make(chan struct{})}
+	saver := newSessionSaver(store, func(err error) { t.Error(err) })
+
+	saver.Save(&Session{ID: "asdf", Sequence: 1})
+	<-store.saving
+	for i := uint64(2); i <= 10; i++ {
+		saver.Save(&Session{ID: "asdf", Sequence: i})
+	}
+	close(store.release)
+	go func() {
+		for range store.saving {
+		}
+	}()
+
+	saver.Close()
+	close(store.saving)
+	assert.Equal(t, []*Session{
+		{ID: "asdf", Sequence: 1},
+		{ID: "asdf", Sequence: 10},
+	}, store.saves)
+
+	saver.Save(&Session{ID: "asdf", Sequence: 11})
+	saver.Close()
+	assert.Len(t, store.saves, 2)
+}
+
+// failingSessionStore is a SessionStore which fails every save.
+type failingSessionStore struct {
+	mu    sync.Mutex
+	err   error
+	saved chan struct{}
+}
+
+func (f *failingSessionStore) Load() (*Session, error) { return nil, nil }
+
+func (f *failingSessionStore) Save(s *Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.saved <- struct{}{}
+	return f.err
+}
+
+func TestSessionSaverReportsRepeatedErrorsOnce(t *testing.T) {
+	store := &failingSessionStore{err: errors.New("disk full"), saved: make(chan struct{}, 4)}
+	errs := make(chan error, 4)
+	saver := newSessionSaver(store, func(err error) { errs <- err })
+
+	for i := uint64(1); i <= 3; i++ {
+		saver.Save(&Session{ID: "asdf", Sequence: i})
+		<-store.saved
+	}
+	store.mu.Lock()
+	store.err = errors.New("disk on fire")
+	store.mu.Unlock()
+	saver.Save(&Session{ID: "asdf", Sequence: 4})
+	saver.Close()
+	close(errs)
+
+	var got []error
+	for err := range errs {
+		got = append(got, err)
+	}
+	assert.Equal(t, []error{errors.New("disk full"), errors.New("disk on fire")}, got)
+}
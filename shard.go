@@ -0,0 +1,399 @@
+package cord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/WatchBeam/cord/events"
+	"github.com/WatchBeam/cord/model"
+)
+
+// identifyInterval is how long Discord requires between identifies in the
+// same concurrency bucket.
+var identifyInterval = 5 * time.Second
+
+// ShardOptions is passed to NewSharded() to configure the ShardManager.
+type ShardOptions struct {
+	// Shards is the total number of shards to open. Each guild is held by
+	// exactly one shard. Defaults to the number Discord recommends.
+	Shards int
+
+	// MaxConcurrency is the number of shards which may identify at the
+	// same time. Defaults to the bot's limit if Shards is not given, or
+	// one otherwise.
+	MaxConcurrency int
+
+	// Websocket configures each shard's connection. The `shard` field in
+	// the handshake will be filled for you, and the gateway defaults to a
+	// BotGatewayRetriever, whose lookups are shared by all shards. Note
+	// that a Backoff given here is shared by all shards, and so must be
+	// safe for concurrent use. Its SessionStore is ignored, since each
+	// shard has its own session; use SessionStore below instead.
+	Websocket *WsOptions
+
+	// SessionStore, if given, returns the store used to persist the given
+	// shard's session.
+	SessionStore func(shard int) SessionStore
+}
+
+func (s *ShardOptions) fillDefaults(token string) error {
+	if s.Websocket == nil {
+		s.Websocket = &WsOptions{}
+	}
+
+	if s.Websocket.Gateway == nil {
+		timeout := s.Websocket.Timeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+
+		s.Websocket.Gateway = BotGatewayRetriever{
+			Client:  &http.Client{Timeout: timeout},
+			BaseURL: defaultAPIBase,
+			Token:   token,
+		}
+	}
+
+	// Every shard looks up the gateway before identifying, so share one
+	// lookup between them rather than calling /gateway/bot for each.
+	if retriever, ok := s.Websocket.Gateway.(botGatewayRetriever); ok {
+		if _, cached := retriever.(*gatewayCache); !cached {
+			s.Websocket.Gateway = newGatewayCache(retriever)
+		}
+	}
+
+	if s.Shards == 0 {
+		cache, ok := s.Websocket.Gateway.(*gatewayCache)
+		if !ok {
+			return fmt.Errorf("cord/shard: the number of shards must be given " +
+				"unless the gateway retriever can recommend it")
+		}
+
+		gw, err := cache.lookup(false)
+		if err != nil {
+			return err
+		}
+
+		s.Shards = gw.Shards
+		if s.MaxConcurrency == 0 {
+			s.MaxConcurrency = gw.SessionStartLimit.MaxConcurrency
+		}
+	}
+
+	if s.Shards < 1 {
+		s.Shards = 1
+	}
+
+	if s.MaxConcurrency < 1 {
+		s.MaxConcurrency = 1
+	}
+
+	return nil
+}
+
+// forShard returns a copy of the websocket options for the given shard.
+// Stateful defaults, such as the backoff, are not shared between shards
+// unless the user provided them.
+func (s *ShardOptions) forShard(id int) *WsOptions {
+	opts := *s.Websocket
+
+	handshake := model.Handshake{}
+	if opts.Handshake != nil {
+		handshake = *opts.Handshake
+	}
+	handshake.Shard = []int{id, s.Shards}
+	opts.Handshake = &handshake
+
+	opts.SessionStore = nil
+	if s.SessionStore != nil {
+		opts.SessionStore = s.SessionStore(id)
+	}
+
+	return &opts
+}
+
+// identifyLimiter makes sure that only one shard in each concurrency bucket
+// identifies during the identifyInterval.
+type identifyLimiter struct {
+	buckets []identifyBucket
+}
+
+type identifyBucket struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+func newIdentifyLimiter(concurrency int) *identifyLimiter {
+	return &identifyLimiter{buckets: make([]identifyBucket, concurrency)}
+}
+
+// Wait blocks until the shard is allowed to identify. It returns false
+// if the done channel is closed first.
+func (i *identifyLimiter) Wait(shard int, done <-chan struct{}) bool {
+	bucket := &i.buckets[shard%len(i.buckets)]
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	if wait := bucket.last.Add(identifyInterval).Sub(time.Now()); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-done:
+			return false
+		}
+	}
+	bucket.last = time.Now()
+
+	return true
+}
+
+// ShardManager is an implementation of the Socket interface which spreads
+// guilds over several Websocket sessions. Events from all shards are
+// dispatched to the same handlers, and errors from all shards are sent
+// down the same Errs() channel.
+type ShardManager struct {
+	shards []*Websocket
+	errs   *errChannel
+
+	// mu guards the aggregate state of the shards.
+	mu     sync.Mutex
+	state  State
+	states stateNotifier
+
+	// streams are the channels returned from Subscribe, which are closed
+	// once every shard is.
+	streams streamSet
+}
+
+var _ Socket = &ShardManager{}
+
+// NewSharded creates a ShardManager and connects all its shards to the
+// Discord servers. Options may be nil if you want to use the defaults. An
+// error is returned only if the number of shards was not given and could
+// not be looked up.
+func NewSharded(token string, options *ShardOptions) (*ShardManager, error) {
+	if options == nil {
+		options = &ShardOptions{}
+	}
+	if err := options.fillDefaults(token); err != nil {
+		return nil, err
+	}
+
+	m := &ShardManager{
+		shards: make([]*Websocket, options.Shards),
+		errs:   newErrChannel(),
+	}
+
+	events := newEmitter()
+	limiter := newIdentifyLimiter(options.MaxConcurrency)
+	for i := range m.shards {
+		id := i
+		ws := newWebsocket(token, options.forShard(id), events, m.errs)
+		ws.beforeIdentify = func(done <-chan struct{}) bool { return limiter.Wait(id, done) }
+		ws.OnStateChange(func(old, new State) { m.updateState() })
+		m.shards[id] = ws
+	}
+
+	for _, ws := range m.shards {
+		ws.start()
+	}
+
+	return m, nil
+}
+
+// ShardFor returns the ID of the shard which holds the guild. It returns
+// zero if the guild ID is not a valid snowflake.
+func (m *ShardManager) ShardFor(guildID string) int {
+	id, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return int((id >> 22) % uint64(len(m.shards)))
+}
+
+// Send implements Socket.Send. Guild-scoped operations are sent on the
+// shard holding the guild, status updates are sent on every shard, and all
+// other operations are sent on the first shard.
+func (m *ShardManager) Send(op Operation, data json.Marshaler) error {
+	return m.SendContext(context.Background(), op, data)
+}
+
+// SendContext implements Socket.SendContext, routing operations like Send.
+func (m *ShardManager) SendContext(ctx context.Context, op Operation, data json.Marshaler) error {
+	return m.route(op, data, func(ws *Websocket, b json.RawMessage) error {
+		return ws.SendContext(ctx, op, b)
+	})
+}
+
+// TrySend implements Socket.TrySend, routing operations like Send.
+func (m *ShardManager) TrySend(op Operation, data json.Marshaler) error {
+	return m.route(op, data, func(ws *Websocket, b json.RawMessage) error {
+		return ws.TrySend(op, b)
+	})
+}
+
+// route marshals the data and calls send for each shard the operation
+// should be sent on, returning the first error.
+func (m *ShardManager) route(op Operation, data json.Marshaler,
+	send func(ws *Websocket, b json.RawMessage) error) error {
+
+	b, err := data.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case RequestMembers, VoiceStatusUpdate:
+		packet := &guildScopedPacket{}
+		if err := packet.UnmarshalJSON(b); err != nil {
+			return err
+		}
+
+		return send(m.shards[m.ShardFor(packet.GuildID)], b)
+
+	case StatusUpdate:
+		var firstErr error
+		for _, ws := range m.shards {
+			if err := send(ws, b); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		return firstErr
+
+	default:
+		return send(m.shards[0], b)
+	}
+}
+
+// On implements Socket.On. Since all shards share their handlers, this
+// attaches the handler to every shard.
+func (m *ShardManager) On(h events.Handler) Subscription { return m.shards[0].On(h) }
+
+// Once implements Socket.Once. The handler is called once, for the first
+// shard to receive the event.
+func (m *ShardManager) Once(h events.Handler) Subscription { return m.shards[0].Once(h) }
+
+// Off implements Socket.Off
+func (m *ShardManager) Off(h events.Handler) { m.shards[0].Off(h) }
+
+// Subscribe implements Socket.Subscribe. Like handlers, the channel receives
+// events from every shard.
+func (m *ShardManager) Subscribe(name string, size int) (<-chan events.Event, func()) {
+	s := m.streams.Subscribe(m.shards[0].events, name, size, m.shards[0].opts.SlowConsumer)
+	return s.ch, s.Close
+}
+
+// Use implements Socket.Use. Like handlers, middleware is shared by every
+// shard.
+func (m *ShardManager) Use(mw Middleware) { m.shards[0].Use(mw) }
+
+// State implements Socket.State. It returns the least advanced state of
+// any shard that's still open, so it's StateConnected only once every
+// shard is connected, and StateClosed only once every shard is closed.
+func (m *ShardManager) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.state
+}
+
+// OnStateChange implements Socket.OnStateChange. Handlers are called when
+// the aggregate state returned from State() changes.
+func (m *ShardManager) OnStateChange(h func(old, new State)) { m.states.On(h) }
+
+// updateState recomputes the aggregate state after a shard's changes.
+func (m *ShardManager) updateState() {
+	m.mu.Lock()
+	state := StateClosed
+	for _, ws := range m.shards {
+		if s := ws.State(); s < state {
+			state = s
+		}
+	}
+
+	if state != m.state {
+		m.states.Push(m.state, state)
+		m.state = state
+	}
+	m.mu.Unlock()
+
+	m.states.Flush()
+	if state == StateClosed {
+		m.streams.Close()
+	}
+}
+
+// Budget implements Socket.Budget. It returns the budget of the shard with
+// the fewest commands remaining, with Queued summed over all shards.
+func (m *ShardManager) Budget() Budget {
+	var budget Budget
+	queued := 0
+	for i, ws := range m.shards {
+		b := ws.Budget()
+		queued += b.Queued
+		if i == 0 || b.Commands < budget.Commands {
+			budget = b
+		}
+	}
+	budget.Queued = queued
+
+	return budget
+}
+
+// SequenceStats implements Socket.SequenceStats, summing the stats of
+// every shard.
+func (m *ShardManager) SequenceStats() SequenceStats {
+	var stats SequenceStats
+	for _, ws := range m.shards {
+		stats = stats.add(ws.SequenceStats())
+	}
+
+	return stats
+}
+
+// Errs implements Socket.Errs
+func (m *ShardManager) Errs() <-chan error { return m.errs.ch }
+
+// Shutdown implements Socket.Shutdown. It shuts down every shard at once,
+// returning the first error encountered.
+func (m *ShardManager) Shutdown(ctx context.Context) error {
+	errs := make(chan error, len(m.shards))
+	for _, ws := range m.shards {
+		go func(ws *Websocket) { errs <- ws.shutdown(ctx) }(ws)
+	}
+
+	var firstErr error
+	for range m.shards {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	m.streams.Close()
+	m.errs.Close()
+	return firstErr
+}
+
+// Close implements Socket.Close. It closes every shard, returning the first
+// error encountered.
+func (m *ShardManager) Close() error {
+	var firstErr error
+	for _, ws := range m.shards {
+		if err := ws.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	m.streams.Close()
+	m.errs.Close()
+	return firstErr
+}
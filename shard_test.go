@@ -0,0 +1,181 @@
+package cord
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WatchBeam/cord/events"
+	"github.com/WatchBeam/cord/model"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+type shardMessage struct {
+	shard int
+	msg   string
+}
+
+// newShardServer starts a gateway which identifies shards and reports the
+// first packet sent by each one after READY.
+func newShardServer(t *testing.T, received chan<- shardMessage) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		sendHello(c)
+		_, msg, err := c.ReadMessage()
+		assert.Nil(t, err)
+
+		var identify struct {
+			D model.Handshake `json:"d"`
+		}
+		assert.Nil(t, json.Unmarshal(msg, &identify))
+		assert.Len(t, identify.D.Shard, 2)
+		assert.Equal(t, 2, identify.D.Shard[1])
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+
+		_, msg, err = c.ReadMessage()
+		if err == nil {
+			received <- shardMessage{identify.D.Shard[0], string(msg)}
+		}
+	}))
+}
+
+func TestShardManagerRoutesGuildPackets(t *testing.T) {
+	received := make(chan shardMessage, 2)
+	ts := newShardServer(t, received)
+	defer ts.Close()
+
+	m, err := NewSharded("tooken", &ShardOptions{
+		Shards:         2,
+		MaxConcurrency: 2,
+		Websocket: &WsOptions{
+			Gateway: testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+		},
+	})
+	assert.Nil(t, err)
+	defer m.Close()
+
+	ready := make(chan struct{}, 2)
+	m.On(events.Ready(func(r *model.Ready) { ready <- struct{}{} }))
+	<-ready
+	<-ready
+
+	assert.Equal(t, 0, m.ShardFor("41771983423143937"))
+	assert.Equal(t, 1, m.ShardFor("41771983427338241"))
+
+	go m.Send(RequestMembers, json.RawMessage(`{"guild_id":"41771983427338241"}`))
+	msg := <-received
+	assert.Equal(t, 1, msg.shard)
+	assert.Contains(t, msg.msg, `"op":8`)
+
+	go m.Send(VoiceStatusUpdate, json.RawMessage(`{"guild_id":"41771983423143937"}`))
+	msg = <-received
+	assert.Equal(t, 0, msg.shard)
+	assert.Contains(t, msg.msg, `"op":4`)
+}
+
+func TestShardOptionsUseRecommendedShards(t *testing.T) {
+	opts := &ShardOptions{Websocket: &WsOptions{Gateway: testBotGatewayRetriever{BotGateway{
+		Shards:            4,
+		SessionStartLimit: SessionStartLimit{MaxConcurrency: 2},
+	}}}}
+
+	assert.Nil(t, opts.fillDefaults("tooken"))
+	assert.Equal(t, 4, opts.Shards)
+	assert.Equal(t, 2, opts.MaxConcurrency)
+	assert.Equal(t, []int{3, 4}, opts.forShard(3).Handshake.Shard)
+
+	_, err := NewSharded("tooken", &ShardOptions{Websocket: &WsOptions{
+		Gateway: testGatewayRetriever{"ws://localhost"},
+	}})
+	assert.NotNil(t, err)
+}
+
+func TestIdentifyLimiterSpacesIdentifiesInBucket(t *testing.T) {
+	defer func(prev time.Duration) { identifyInterval = prev }(identifyInterval)
+	identifyInterval = 50 * time.Millisecond
+
+	limiter := newIdentifyLimiter(2)
+	start := time.Now()
+	limiter.Wait(0, nil)
+	limiter.Wait(1, nil)
+	assert.True(t, time.Since(start) < identifyInterval, "shards in different buckets shouldn't wait")
+
+	limiter.Wait(2, nil)
+	assert.True(t, time.Since(start) >= identifyInterval, "shards in the same bucket should wait")
+}
+
+func TestShardManagerAggregatesState(t *testing.T) {
+	m := &ShardManager{}
+	for i := 0; i < 2; i++ {
+		ws := newDisconnectedWebsocket()
+		ws.OnStateChange(func(old, new State) { m.updateState() })
+		m.shards = append(m.shards, ws)
+	}
+
+	var transitions []State
+	m.OnStateChange(func(old, new State) { transitions = append(transitions, new) })
+
+	m.shards[0].setState(StateConnected)
+	assert.Equal(t, StateConnecting, m.State())
+	m.shards[1].setState(StateResuming)
+	m.shards[1].setState(StateConnected)
+	assert.Equal(t, StateConnected, m.State())
+
+	m.shards[0].Close()
+	assert.Equal(t, StateConnected, m.State())
+	m.shards[1].Close()
+	assert.Equal(t, StateClosed, m.State())
+
+	assert.Equal(t, []State{StateResuming, StateConnected, StateClosed}, transitions)
+}
+
+func TestShardsWaitToIdentifyBeforeDialing(t *testing.T) {
+	defer func(prev time.Duration) { identifyInterval = prev }(identifyInterval)
+	identifyInterval = 30 * time.Millisecond
+
+	// Like Discord, drop shards which take too long to identify after
+	// Hello. Six shards in one bucket take 150ms to identify, so they'd
+	// be dropped if they waited on an open socket.
+	const shards, silence = 6, 60 * time.Millisecond
+	identified := make(chan time.Duration, shards)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		sendHello(c)
+		hello := time.Now()
+		if _, _, err := c.ReadMessage(); err != nil {
+			return
+		}
+		identified <- time.Since(hello)
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+		c.ReadMessage()
+	}))
+	defer ts.Close()
+
+	m, err := NewSharded("tooken", &ShardOptions{
+		Shards:         shards,
+		MaxConcurrency: 1,
+		Websocket: &WsOptions{
+			Gateway: testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+		},
+	})
+	assert.Nil(t, err)
+	defer m.Close()
+
+	for i := 0; i < shards; i++ {
+		assert.True(t, <-identified < silence, "expected shards to identify soon after Hello")
+	}
+}
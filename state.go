@@ -0,0 +1,97 @@
+package cord
+
+import "sync"
+
+// A State describes the connection of a Socket to the gateway.
+type State uint8
+
+const (
+	// StateConnecting means the socket is looking up the gateway, dialing
+	// it, or waiting to do so after a disruption.
+	StateConnecting State = iota
+	// StateIdentifying means the socket is starting a new session.
+	StateIdentifying
+	// StateResuming means the socket is resuming a previous session.
+	StateResuming
+	// StateConnected means the socket has a session and is receiving
+	// events.
+	StateConnected
+	// StateClosed means the socket was closed, or hit a FatalError, and
+	// will not reconnect.
+	StateClosed
+)
+
+var stateNames = [...]string{
+	StateConnecting:  "connecting",
+	StateIdentifying: "identifying",
+	StateResuming:    "resuming",
+	StateConnected:   "connected",
+	StateClosed:      "closed",
+}
+
+// String returns the name of the state.
+func (s State) String() string {
+	if int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+
+	return "unknown"
+}
+
+// stateTransition is a change from one State to another.
+type stateTransition struct{ old, new State }
+
+// stateNotifier calls handlers for state transitions, in the order they
+// happened. Transitions are queued with Push while holding whatever lock
+// guards the state, and delivered by Flush once it's released. Handlers
+// are free to cause further transitions; those are delivered after the
+// current one by the same Flush.
+type stateNotifier struct {
+	mu        sync.Mutex
+	handlers  []func(old, new State)
+	pending   []stateTransition
+	notifying bool
+}
+
+// On adds a handler to be called on each transition.
+func (s *stateNotifier) On(h func(old, new State)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers = append(s.handlers, h)
+}
+
+// Push queues a transition for delivery.
+func (s *stateNotifier) Push(old, new State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(s.pending, stateTransition{old, new})
+}
+
+// Flush delivers queued transitions, unless another call is already doing
+// so.
+func (s *stateNotifier) Flush() {
+	s.mu.Lock()
+	if s.notifying {
+		s.mu.Unlock()
+		return
+	}
+	s.notifying = true
+
+	for len(s.pending) > 0 {
+		t := s.pending[0]
+		s.pending = s.pending[1:]
+		handlers := s.handlers
+		s.mu.Unlock()
+
+		for _, h := range handlers {
+			h(t.old, t.new)
+		}
+
+		s.mu.Lock()
+	}
+
+	s.notifying = false
+	s.mu.Unlock()
+}
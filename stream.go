@@ -0,0 +1,178 @@
+package cord
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/WatchBeam/cord/events"
+)
+
+// A SlowConsumerPolicy determines what happens to events for a Subscribe
+// channel which is full.
+type SlowConsumerPolicy uint8
+
+const (
+	// SlowConsumerDrop drops events which don't fit in the channel. This
+	// is the default.
+	SlowConsumerDrop SlowConsumerPolicy = iota
+	// SlowConsumerBlock waits for the channel to have room. This holds up
+	// every handler called on the same dispatch worker.
+	SlowConsumerBlock
+	// SlowConsumerDisconnect cancels the subscription, closing the channel,
+	// and reports a SlowConsumerError.
+	SlowConsumerDisconnect
+)
+
+// A SlowConsumerError is reported, wrapped in a HandlerError, when a
+// subscription is cancelled by SlowConsumerDisconnect.
+type SlowConsumerError struct {
+	// Event is the event which didn't fit in the channel.
+	Event string
+}
+
+// Error implements error.Error
+func (s SlowConsumerError) Error() string {
+	return fmt.Sprintf("cord/websocket: subscription was too slow to receive %s", s.Event)
+}
+
+// stream is the handler behind a Subscribe channel. Like an errChannel, it
+// may be closed while events are being sent on it.
+type stream struct {
+	name   string
+	policy SlowConsumerPolicy
+
+	mu       sync.RWMutex
+	ch       chan events.Event
+	done     chan struct{}
+	doneOnce sync.Once
+	closed   bool
+
+	// unsubscribe detaches the stream and forgets it.
+	unsubscribe func()
+}
+
+var _ events.NamedHandler = &stream{}
+
+// Name implements Handler.Name
+func (s *stream) Name() string { return s.name }
+
+// Invoke implements Handler.Invoke
+func (s *stream) Invoke(b []byte) error { return s.InvokeNamed(s.name, b) }
+
+// InvokeNamed implements NamedHandler.InvokeNamed
+func (s *stream) InvokeNamed(name string, b []byte) error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return nil
+	}
+
+	ev := events.Event{Name: name, Data: b}
+	if s.policy == SlowConsumerBlock {
+		select {
+		case s.ch <- ev:
+		case <-s.done:
+		}
+
+		s.mu.RUnlock()
+		return nil
+	}
+
+	select {
+	case s.ch <- ev:
+		s.mu.RUnlock()
+		return nil
+	default:
+		s.mu.RUnlock()
+	}
+
+	if s.policy == SlowConsumerDisconnect {
+		s.Close()
+		return SlowConsumerError{Event: name}
+	}
+
+	return nil
+}
+
+// Close detaches the stream and closes its channel. It's safe to call more
+// than once.
+func (s *stream) Close() {
+	// As in errChannel, closing done first releases blocked senders.
+	s.doneOnce.Do(func() {
+		close(s.done)
+		s.unsubscribe()
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// streamSet holds the streams of a socket, so that they can be closed once
+// it will no longer receive events.
+type streamSet struct {
+	mu      sync.Mutex
+	streams map[*stream]struct{}
+	closed  bool
+}
+
+// Subscribe attaches a new stream for the event to the emitter. If the set
+// was already closed, so is the stream.
+func (set *streamSet) Subscribe(e *emitter, name string, size int, policy SlowConsumerPolicy) *stream {
+	s := &stream{
+		name:   name,
+		policy: policy,
+		ch:     make(chan events.Event, size),
+		done:   make(chan struct{}),
+	}
+
+	sub := e.On(s)
+	s.unsubscribe = func() {
+		sub.Unsubscribe()
+
+		set.mu.Lock()
+		delete(set.streams, s)
+		set.mu.Unlock()
+	}
+
+	set.mu.Lock()
+	closed := set.closed
+	if !closed {
+		if set.streams == nil {
+			set.streams = map[*stream]struct{}{}
+		}
+		set.streams[s] = struct{}{}
+	}
+	set.mu.Unlock()
+
+	if closed {
+		s.Close()
+	}
+
+	return s
+}
+
+// Close closes every stream in the set, and any subscribed after.
+func (set *streamSet) Close() {
+	set.mu.Lock()
+	set.closed = true
+	streams := make([]*stream, 0, len(set.streams))
+	for s := range set.streams {
+		streams = append(streams, s)
+	}
+	set.mu.Unlock()
+
+	for _, s := range streams {
+		s.Close()
+	}
+}
+
+// Subscribe implements Socket.Subscribe
+func (w *Websocket) Subscribe(name string, size int) (<-chan events.Event, func()) {
+	s := w.streams.Subscribe(w.events, name, size, w.opts.SlowConsumer)
+	return s.ch, s.Close
+}
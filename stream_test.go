@@ -0,0 +1,105 @@
+package cord
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/WatchBeam/cord/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribeDropsEventsForSlowConsumers(t *testing.T) {
+	ws := newDisconnectedWebsocket()
+	ch, cancel := ws.Subscribe(events.MessageCreateStr, 1)
+
+	assert.Nil(t, ws.events.Dispatch(events.MessageCreateStr, []byte(`{"id":"1"}`)))
+	assert.Nil(t, ws.events.Dispatch(events.MessageCreateStr, []byte(`{"id":"2"}`)))
+	assert.Equal(t, events.Event{Name: events.MessageCreateStr, Data: []byte(`{"id":"1"}`)}, <-ch)
+
+	cancel()
+	cancel()
+	_, ok := <-ch
+	assert.False(t, ok)
+	assert.Nil(t, ws.events.Dispatch(events.MessageCreateStr, []byte(`{"id":"3"}`)))
+}
+
+func TestSubscribeDisconnectsSlowConsumers(t *testing.T) {
+	ws := newDisconnectedWebsocket()
+	ws.opts.SlowConsumer = SlowConsumerDisconnect
+	ch, _ := ws.Subscribe(events.AnyStr, 1)
+
+	assert.Nil(t, ws.events.Dispatch(events.MessageCreateStr, []byte(`{}`)))
+	err := ws.events.Dispatch(events.TypingStartStr, []byte(`{}`))
+	if errs, ok := err.(HandlerErrors); assert.True(t, ok) && assert.Len(t, errs, 1) {
+		assert.Equal(t, SlowConsumerError{Event: events.TypingStartStr}, errs[0].(HandlerError).Err)
+	}
+
+	assert.Equal(t, events.MessageCreateStr, (<-ch).Name)
+	_, ok := <-ch
+	assert.False(t, ok)
+}
+
+func TestSubscribeBlocksForSlowConsumers(t *testing.T) {
+	ws := newDisconnectedWebsocket()
+	ws.opts.SlowConsumer = SlowConsumerBlock
+	ch, _ := ws.Subscribe(events.MessageCreateStr, 0)
+
+	dispatched := make(chan struct{})
+	go func() {
+		ws.events.Dispatch(events.MessageCreateStr, []byte(`{}`))
+		close(dispatched)
+	}()
+
+	select {
+	case <-dispatched:
+		t.Fatal("expected dispatch to wait for the consumer")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	<-ch
+	<-dispatched
+
+	// Closing releases dispatches which are still waiting.
+	go ws.events.Dispatch(events.MessageCreateStr, []byte(`{}`))
+	ws.Close()
+	for range ch {
+	}
+}
+
+func TestSubscribeAfterCloseIsClosed(t *testing.T) {
+	ws := newDisconnectedWebsocket()
+	ws.Close()
+
+	ch, _ := ws.Subscribe(events.MessageCreateStr, 1)
+	_, ok := <-ch
+	assert.False(t, ok)
+}
+
+func TestShardManagerSubscribeOutlivesSingleShards(t *testing.T) {
+	m := &ShardManager{}
+	e := newEmitter()
+	for i := 0; i < 2; i++ {
+		ws := newWebsocket("tooken", &WsOptions{
+			Gateway: testGatewayRetriever{"ws://127.0.0.1:0"},
+		}, e, newErrChannel())
+		ws.ws = unsafe.Pointer(ws.newPendingConn())
+		ws.OnStateChange(func(old, new State) { m.updateState() })
+		m.shards = append(m.shards, ws)
+	}
+
+	ch, _ := m.Subscribe(events.MessageCreateStr, 1)
+
+	// Closing one shard leaves the channel open for the others' events.
+	m.shards[0].Close()
+	assert.Nil(t, e.Dispatch(events.MessageCreateStr, []byte(`{"id":"1"}`)))
+	assert.Equal(t, events.Event{Name: events.MessageCreateStr, Data: []byte(`{"id":"1"}`)}, <-ch)
+
+	m.shards[1].Close()
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	ch, _ = m.Subscribe(events.MessageCreateStr, 1)
+	_, ok = <-ch
+	assert.False(t, ok)
+}
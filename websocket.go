@@ -1,14 +1,15 @@
 package cord
 
 import (
-	"bytes"
-	"compress/zlib"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"net/url"
 	"runtime"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -22,7 +23,9 @@ import (
 // WsOptions is passed to New() to configure the websocket setup.
 type WsOptions struct {
 	// Handshake packet to send to the server. Note that `compress` and
-	// `properties` will be filled for you.
+	// `properties` will be filled for you. If `intents` are given, handlers
+	// attached for events those intents exclude will cause an IntentWarning
+	// to be sent down the Errs() channel.
 	Handshake *model.Handshake
 
 	// How long to wait without frames or acknowledgment before we consider
@@ -46,6 +49,55 @@ type WsOptions struct {
 
 	// Headers to send in the websocket handshake.
 	Header http.Header
+
+	// Compression determines how Discord compresses the data it sends.
+	// Defaults to CompressPayload.
+	Compression CompressionMode
+
+	// Encoding determines the format payloads are sent in. Defaults to
+	// EncodingJSON.
+	Encoding Encoding
+
+	// MaxQueuedSends is the most messages which may wait to be sent, for
+	// instance while reconnecting or when rate limited. Defaults to
+	// unlimited.
+	MaxQueuedSends int
+
+	// QueueOverflow determines what happens to sends when MaxQueuedSends
+	// is reached. Defaults to OverflowRejectNewest.
+	QueueOverflow OverflowPolicy
+
+	// ResumeAfterShutdown makes Shutdown keep the session open, closing
+	// the connection with code 4000 rather than 1000, so that it can be
+	// resumed by a new Websocket using the same SessionStore.
+	ResumeAfterShutdown bool
+
+	// SessionStore, if given, persists the session whenever it changes.
+	// A stored session is resumed when the Websocket starts.
+	SessionStore SessionStore
+
+	// DispatchWorkers is the number of goroutines which call event
+	// handlers. By default there's one, so handlers are called one at a
+	// time, in the order events were received. With more, events with the
+	// same DispatchKey are still handled in order, but events with
+	// different keys may be handled concurrently. Under a ShardManager,
+	// each shard has its own workers, so handlers may be called
+	// concurrently for events from different shards even with one.
+	DispatchWorkers int
+
+	// DispatchKey returns the key which orders events when there's more
+	// than one dispatch worker. Defaults to GuildKey.
+	DispatchKey func(event string, data []byte) string
+
+	// OnOpcode, if given, is called with every payload received which
+	// isn't a Dispatch, including opcodes cord doesn't know, which are
+	// then no longer reported on Errs(). It's called from the goroutine
+	// reading the socket, so it should not block.
+	OnOpcode func(op Operation, data []byte)
+
+	// SlowConsumer determines what happens to events for a Subscribe
+	// channel which is full. Defaults to SlowConsumerDrop.
+	SlowConsumer SlowConsumerPolicy
 }
 
 func (w *WsOptions) fillDefaults(token string) {
@@ -53,6 +105,10 @@ func (w *WsOptions) fillDefaults(token string) {
 		w.Timeout = 10 * time.Second
 	}
 
+	if w.DispatchKey == nil {
+		w.DispatchKey = GuildKey
+	}
+
 	if w.Backoff == nil {
 		eb := backoff.NewExponentialBackOff()
 		eb.InitialInterval = time.Millisecond * 500
@@ -72,7 +128,7 @@ func (w *WsOptions) fillDefaults(token string) {
 	if w.Gateway == nil {
 		w.Gateway = HTTPGatewayRetriever{
 			Client:  &http.Client{Timeout: w.Timeout},
-			BaseURL: "https://discordapp.com/api",
+			BaseURL: defaultAPIBase,
 		}
 	}
 
@@ -84,7 +140,7 @@ func (w *WsOptions) fillDefaults(token string) {
 		w.Debugger = nilDebugger{}
 	}
 
-	w.Handshake.Compress = true
+	w.Handshake.Compress = w.Compression == CompressPayload
 	w.Handshake.Token = token
 	w.Handshake.Properties = model.HandshakeProperties{
 		OS:      runtime.GOOS,
@@ -94,11 +150,58 @@ func (w *WsOptions) fillDefaults(token string) {
 
 // wsConn is a struct atomically stored within a Websocket, containing a
 // websocket connection and a queue of messages to send. When a restart
-// happens, the queue is forked and the websocket connection is
-// reestablished in a new wsConn struct.
+// happens, the queue's messages are moved into a new wsConn struct, in
+// which the websocket connection is reestablished.
 type wsConn struct {
 	ws    *websocket.Conn
 	queue *queue
+
+	// acked is 1 if the last heartbeat we sent was acknowledged by the
+	// server, 0 otherwise. Atomically updated.
+	acked uint32
+	// beat is signaled when the server asks us for an immediate heartbeat.
+	beat chan struct{}
+	// inflater is used to read messages when the connection is compressed
+	// as a zlib stream. Each connection starts a new stream.
+	inflater *zlibStream
+	// limiter holds back queued messages to stay within the connection's
+	// rate limits.
+	limiter *limiter
+	// readDone is closed when the read pump exits.
+	readDone chan struct{}
+}
+
+// newWsConn creates a wsConn for a freshly-established websocket.
+func newWsConn(ws *websocket.Conn, q *queue, compression CompressionMode) *wsConn {
+	cnx := &wsConn{
+		ws:       ws,
+		queue:    q,
+		acked:    1,
+		beat:     make(chan struct{}, 1),
+		limiter:  newLimiter(),
+		readDone: make(chan struct{}),
+	}
+
+	if compression == CompressZlibStream {
+		cnx.inflater = &zlibStream{}
+	}
+
+	return cnx
+}
+
+// decompress returns the payload contained in a message read from the
+// connection. It returns nil if the message contains only part of a
+// payload, which may happen when using zlib-stream compression.
+func (w *wsConn) decompress(b []byte) ([]byte, error) {
+	if w.inflater != nil {
+		return w.inflater.Inflate(b)
+	}
+
+	if isZlib(b) {
+		return inflate(b)
+	}
+
+	return b, nil
 }
 
 // Close closes the associated websocket and queue.
@@ -115,16 +218,6 @@ func (w *wsConn) Close() error {
 	return nil
 }
 
-// Fork creates a new wsConn whose queue inherits from the current one.
-// The websocket itself will be nil.
-func (w *wsConn) Fork() *wsConn {
-	if w == nil {
-		return &wsConn{queue: newQueue()}
-	}
-
-	return &wsConn{queue: w.queue.Fork()}
-}
-
 // A DisruptionError is sent when an error happens which causes the server
 // to try to reconnect to the websocket.
 type DisruptionError struct{ Cause error }
@@ -134,6 +227,32 @@ func (d DisruptionError) Error() string {
 	return fmt.Sprintf("cord/websocket: reconnecting due to: %s", d.Cause)
 }
 
+// ErrHeartbeatTimeout is the cause of a DisruptionError sent when the server
+// fails to acknowledge a heartbeat before the next one is due. This usually
+// indicates a "zombied" connection which is open but no longer receiving.
+var ErrHeartbeatTimeout = errors.New("cord/websocket: heartbeat was not acknowledged")
+
+// ErrClosed is returned when sending on a Websocket which was closed.
+var ErrClosed = errors.New("cord/websocket: the websocket is closed")
+
+// A NotConnectedError is returned from TrySend when the websocket is not
+// connected, such as while it's reconnecting.
+type NotConnectedError struct{ State State }
+
+// Error implements error.Error
+func (n NotConnectedError) Error() string {
+	return fmt.Sprintf("cord/websocket: not connected (%s)", n.State)
+}
+
+// A RateLimitedError is returned from TrySend when the command would
+// exceed the gateway's rate limits.
+type RateLimitedError struct{ Reset time.Time }
+
+// Error implements error.Error
+func (r RateLimitedError) Error() string {
+	return fmt.Sprintf("cord/websocket: rate limited until %s", r.Reset.Format(time.RFC3339))
+}
+
 // A FatalError is sent when an error happens that the websocket cannot
 // recover from.
 type FatalError struct{ Cause error }
@@ -145,151 +264,418 @@ func (d FatalError) Error() string {
 
 // Websocket is an implementation of the Socket interface.
 type Websocket struct {
-	opts   *WsOptions
-	events emitter
-
-	// ws points to a wsConn, atomically updated
+	opts       *WsOptions
+	events     *emitter
+	dispatcher *dispatcher
+
+	// mu guards transitions of the state and connection. ws points to a
+	// wsConn and state holds a State; both are atomically updated while
+	// holding mu, so that they can be read without it.
+	mu        sync.Mutex
 	ws        unsafe.Pointer
+	state     uint32
+	states    stateNotifier
 	sessionID unsafe.Pointer
-	lastSeq   uint64 // atomically updated
-	errs      chan error
+	resumeURL unsafe.Pointer
+	lastSeq   uint64        // atomically updated
+	seqStats  SequenceStats // atomically updated
+	errs      *errChannel
+
+	// dialing is the connection we're handshaking on, if any, so that
+	// Close can interrupt it. It's guarded by mu.
+	dialing *websocket.Conn
+
+	// done is closed when the websocket is closed, to interrupt any
+	// waits before reconnecting.
+	done     chan struct{}
+	doneOnce sync.Once
+
+	// saver writes to the SessionStore, if any.
+	saver *sessionSaver
+
+	// streams are the channels returned from Subscribe, which are closed
+	// along with the websocket.
+	streams streamSet
+
+	// beforeIdentify, if set, is called before dialing a connection which
+	// will identify. The ShardManager uses it to respect identify
+	// concurrency. Waiting happens before dialing since we can't send
+	// heartbeats during the handshake, and Discord drops connections
+	// which are silent for too long. It returns false if the done channel
+	// was closed while waiting.
+	beforeIdentify func(done <-chan struct{}) bool
+}
+
+// start loads the stored session, if any, and boots the websocket
+// asynchronously. Messages sent in the meantime are queued.
+func (w *Websocket) start() {
+	w.loadSession()
+	w.dispatcher.Start(w.done, func(err error) {
+		var errs HandlerErrors
+		switch err := err.(type) {
+		case HandlerErrors:
+			errs = err
+		case MiddlewarePanicError:
+			errs = HandlerErrors{err}
+		default:
+			errs = HandlerErrors{fmt.Errorf("cord/websocket: error dispatching event: %s", err)}
+		}
+
+		// Don't hold up the next event until someone reads the errors.
+		go func() {
+			for _, err := range errs {
+				w.sendErr(err)
+			}
+		}()
+	})
+
+	cnx := w.newPendingConn()
+	w.transition(nil, cnx, StateConnecting)
+	go w.connect(cnx)
 }
 
-// start boots the websocket asynchronously.
-func (w *Websocket) start() { go w.restart(nil, nil) }
+// newPendingConn returns a wsConn to queue messages on until a connection
+// is established.
+func (w *Websocket) newPendingConn() *wsConn {
+	return &wsConn{queue: newQueue(w.opts.MaxQueuedSends, w.opts.QueueOverflow)}
+}
 
 // restart closes the server and attempts to reconnect to Discord. It takes
 // an optional error to log down. If the error is of type FatalError, restart
-// will exit after sending it without attempting to reconnect.
+// will exit after sending it without attempting to reconnect. Close frames
+// are converted into a CloseError and handled according to the code's
+// Behavior.
 func (w *Websocket) restart(err error, prev *wsConn) {
-	next := prev.Fork()
+	next := w.newPendingConn()
+
+	reidentify := false
+	if cerr, ok := closeError(err); ok {
+		switch cerr.Code.Behavior() {
+		case CloseFatal:
+			err = FatalError{cerr}
+		case CloseReidentify:
+			reidentify = true
+			err = cerr
+		default:
+			err = cerr
+		}
+	}
+
+	_, isFatal := err.(FatalError)
+	state := StateConnecting
+	if isFatal {
+		state = StateClosed
+	}
 
 	// If someone already restarted or closed us, do nothing.
-	if !atomic.CompareAndSwapPointer(&w.ws, unsafe.Pointer(prev), unsafe.Pointer(next)) {
+	if !w.transition(prev, next, state) {
 		return
 	}
+
+	// Messages which were waiting on the previous connection will be sent
+	// on the next one.
+	if prev != nil {
+		prev.queue.MoveTo(next.queue)
+	}
 	prev.Close()
 
-	if _, isFatal := err.(FatalError); isFatal {
+	if reidentify {
+		w.resetSession()
+	}
+
+	if isFatal {
+		next.queue.Fail(err)
+		w.streams.Close()
 		w.sendErr(err)
 		return
 	} else if err != nil {
 		w.sendErr(DisruptionError{err})
-		time.Sleep(w.opts.Backoff.NextBackOff())
+		if !w.sleep(w.opts.Backoff.NextBackOff()) {
+			return
+		}
 	}
 
-	// Look up the websocket address to connect to.
-	gateway, err := w.opts.Gateway.Gateway()
+	w.connect(next)
+}
+
+// connect looks up the gateway and connects to it.
+func (w *Websocket) connect(cnx *wsConn) {
+	gateway, err := w.lookupGateway()
 	if err != nil {
-		w.restart(err, next)
+		w.restart(err, cnx)
+		return
+	}
+
+	w.establishSocketConnection(gateway, cnx)
+}
+
+// sleep waits for the duration, returning false if the websocket was
+// closed in the meantime.
+func (w *Websocket) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// transition replaces the connection and moves to the given state. It
+// returns false, doing nothing, if the connection is no longer current or
+// the websocket was closed.
+func (w *Websocket) transition(from, to *wsConn, state State) bool {
+	w.mu.Lock()
+	if w.State() == StateClosed || (*wsConn)(atomic.LoadPointer(&w.ws)) != from {
+		w.mu.Unlock()
+		return false
+	}
+
+	atomic.StorePointer(&w.ws, unsafe.Pointer(to))
+	w.storeState(state)
+	w.mu.Unlock()
+
+	w.states.Flush()
+	return true
+}
+
+// setState moves to the given state, unless the websocket was closed.
+func (w *Websocket) setState(state State) {
+	w.mu.Lock()
+	if w.State() != StateClosed {
+		w.storeState(state)
+	}
+	w.mu.Unlock()
+
+	w.states.Flush()
+}
+
+// storeState updates the state and queues the transition for handlers.
+// It must be called while holding mu.
+func (w *Websocket) storeState(state State) {
+	old := w.State()
+	if old == state {
 		return
 	}
 
-	w.establishSocketConnection(gateway, next)
+	atomic.StoreUint32(&w.state, uint32(state))
+	w.states.Push(old, state)
+}
+
+// lookupGateway returns the address of the gateway to connect to. When
+// resuming, that's the resume_gateway_url given in the session's READY,
+// falling back to the gateway the session was started on. If the
+// retriever reports session start limits and we're about to identify with
+// no sessions remaining, it waits for the limit to reset first.
+func (w *Websocket) lookupGateway() (string, error) {
+	resuming := atomic.LoadPointer(&w.sessionID) != nil
+	if u := (*string)(atomic.LoadPointer(&w.resumeURL)); resuming && u != nil {
+		return *u, nil
+	}
+
+	retriever, ok := w.opts.Gateway.(botGatewayRetriever)
+	if !ok || resuming {
+		return w.opts.Gateway.Gateway()
+	}
+
+	gw, err := retriever.BotGateway()
+	if err != nil {
+		return "", err
+	}
+
+	if gw.SessionStartLimit.Remaining <= 0 && !w.sleep(gw.SessionStartLimit.ResetAfterDuration()) {
+		return "", ErrClosed
+	}
+
+	return gw.URL, nil
 }
 
 type sessionDetails struct {
 	SessionID string
 	Heartbeat uint
+	// ResumeURL is the gateway to dial when resuming the session. It's
+	// empty if the server didn't give us one.
+	ResumeURL string
+}
+
+// gatewayURL adds the query parameters for our connection options to the
+// gateway address.
+func (w *Websocket) gatewayURL(gateway string) (string, error) {
+	u, err := url.Parse(gateway)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	if w.opts.Compression == CompressZlibStream {
+		query.Set("compress", "zlib-stream")
+	}
+	if w.opts.Encoding == EncodingETF {
+		query.Set("encoding", "etf")
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
 }
 
 func (w *Websocket) establishSocketConnection(gateway string, cnx *wsConn) {
-	w.opts.Debugger.Connecting(gateway)
-	ws, _, err := w.opts.Dialer.Dial(gateway, w.opts.Header)
+	address, err := w.gatewayURL(gateway)
 	if err != nil {
 		w.restart(err, cnx)
 		return
 	}
 
-	details, err := w.runHandshake(ws)
+	resuming := atomic.LoadPointer(&w.sessionID) != nil
+	if !resuming && w.beforeIdentify != nil && !w.beforeIdentify(w.done) {
+		return
+	}
+
+	w.opts.Debugger.Connecting(address)
+	ws, _, err := w.opts.Dialer.Dial(address, w.opts.Header)
 	if err != nil {
 		w.restart(err, cnx)
 		return
 	}
+	if !w.setDialing(cnx, ws) {
+		ws.Close()
+		return
+	}
 
-	next := &wsConn{
-		queue: cnx.queue,
-		ws:    ws,
+	next := newWsConn(ws, cnx.queue, w.opts.Compression)
+	details, err := w.runHandshake(next)
+	w.setDialing(cnx, nil)
+	if err != nil {
+		ws.Close()
+		w.restart(err, cnx)
+		return
 	}
 
 	// Note: we store a new pointer rather than updating the cnx because
 	// someone else might have read the wsConn pointer in the meantime.
-	atomic.StorePointer(&w.ws, unsafe.Pointer(unsafe.Pointer(next)))
+	if !w.transition(cnx, next, StateConnected) {
+		ws.Close()
+		return
+	}
 	w.opts.Backoff.Reset()
 
 	atomic.StorePointer(&w.sessionID, unsafe.Pointer(&details.SessionID))
+	if details.ResumeURL == "" {
+		details.ResumeURL = gateway
+	}
+	atomic.StorePointer(&w.resumeURL, unsafe.Pointer(&details.ResumeURL))
+	w.saveSession()
 	interval := time.Duration(details.Heartbeat) * time.Millisecond
 
 	go w.readPump(next)
 	go w.writePump(next, interval)
 }
 
+// setDialing records the connection we're handshaking on in place of the
+// pending cnx. It returns false if the websocket was closed or restarted.
+func (w *Websocket) setDialing(cnx *wsConn, ws *websocket.Conn) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.State() == StateClosed || (*wsConn)(atomic.LoadPointer(&w.ws)) != cnx {
+		return false
+	}
+
+	w.dialing = ws
+	return true
+}
+
+// readPayload reads a single payload from the websocket, waiting at most
+// the configured timeout.
+func (w *Websocket) readPayload(cnx *wsConn) (*Payload, error) {
+	cnx.ws.SetReadDeadline(time.Now().Add(w.opts.Timeout))
+
+	for {
+		_, message, err := cnx.ws.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := cnx.decompress(message)
+		if err != nil {
+			return nil, err
+		}
+
+		if b != nil {
+			payload, err := w.unmarshalPayload(b)
+			if err == nil {
+				w.observe(payload)
+			}
+
+			return payload, err
+		}
+	}
+}
+
 // invokeWithResponse attempts to write the operation to the websocket and
 // immediately read a result back with a timeout.
-func (w *Websocket) invokeWithResponse(ws *websocket.Conn, op Operation, data json.Marshaler) (*Payload, error) {
+func (w *Websocket) invokeWithResponse(cnx *wsConn, op Operation, data json.Marshaler) (*Payload, error) {
 	data, err := w.marshalPayload(op, data)
 	if err != nil {
 		return nil, FatalError{err}
 	}
 
-	if err = w.writeMessage(ws, data); err != nil {
+	cnx.limiter.Force()
+	if err = w.writeMessage(cnx.ws, data); err != nil {
 		return nil, err
 	}
 
-	ws.SetReadDeadline(time.Now().Add(w.opts.Timeout))
-	_, message, err := ws.ReadMessage()
-	if err != nil {
-		return nil, err
-	}
-
-	return w.unmarshalPayload(message)
+	return w.readPayload(cnx)
 }
 
 // runHandshakeResume attempts to continue a previously disconnected session
 // on the websocket. It calls back to runHandshakeNew if the session is
-// deemed invalid.
-func (w *Websocket) runHandshakeResume(ws *websocket.Conn, sessionID string) (details sessionDetails, err error) {
-	payload, err := w.invokeWithResponse(ws, Resume, &model.Resume{
+// deemed invalid. Events the server replays before RESUMED are dispatched
+// as usual.
+func (w *Websocket) runHandshakeResume(cnx *wsConn, sessionID string) (details sessionDetails, err error) {
+	payload, err := w.invokeWithResponse(cnx, Resume, &model.Resume{
 		Token:     w.opts.Handshake.Token,
 		SessionID: sessionID,
 		Sequence:  atomic.LoadUint64(&w.lastSeq),
 	})
-	if err != nil {
-		return details, err
-	}
 
-	switch payload.Operation {
-	case Dispatch:
-		if payload.Event != events.ResumedStr {
-			return details, fmt.Errorf("cord/websocket: expected to get %s event, got %+v",
-				events.ResumedStr, payload)
-		}
+	for ; err == nil; payload, err = w.readPayload(cnx) {
+		switch payload.Operation {
+		case Dispatch:
+			w.receive(payload)
+			if payload.Event == events.ResumedStr {
+				details.SessionID = sessionID
+				return details, nil
+			}
 
-		err = events.Resumed(func(r *model.Resumed) {
-			details.Heartbeat = r.HeartbeatInterval
-			details.SessionID = sessionID
-		}).Invoke(payload.Data)
-		go w.events.Dispatch(payload.Event, payload.Data)
-		return details, nil
+		case InvalidSession:
+			// If we have to wait for our turn to identify, reconnect so
+			// that we wait before dialing rather than on an open socket.
+			if w.beforeIdentify != nil {
+				w.resetSession()
+				return details, fmt.Errorf("cord/websocket: invalid session detected")
+			}
 
-	case InvalidSession:
-		return w.runHandshakeNew(ws)
-	default:
-		return details, fmt.Errorf("cord/websocket: expected to get opcode %d or %d, %d",
-			Dispatch,
-			InvalidSession,
-			payload.Operation,
-		)
+			w.setState(StateIdentifying)
+			return w.runHandshakeNew(cnx)
+		default:
+			return details, fmt.Errorf("cord/websocket: expected to get opcode %d or %d, %d",
+				Dispatch,
+				InvalidSession,
+				payload.Operation,
+			)
+		}
 	}
+
+	return details, err
 }
 
 // runHandshakeNew attempts to authenticate a new session on the websocket.
-func (w *Websocket) runHandshakeNew(ws *websocket.Conn) (details sessionDetails, err error) {
-	payload, err := w.invokeWithResponse(ws, Identify, w.opts.Handshake)
-
-	// If the token the user provided is invalid, die, we can't do anything.
-	if wserr, ok := err.(*websocket.CloseError); ok && wserr.Code == 4004 {
-		return details, FatalError{err}
+func (w *Websocket) runHandshakeNew(cnx *wsConn) (details sessionDetails, err error) {
+	payload, err := w.invokeWithResponse(cnx, Identify, w.opts.Handshake)
+	if err != nil {
+		return details, err
 	}
 
 	if payload.Event != events.ReadyStr {
@@ -298,29 +684,68 @@ func (w *Websocket) runHandshakeNew(ws *websocket.Conn) (details sessionDetails,
 	}
 
 	err = events.Ready(func(r *model.Ready) {
-		details.Heartbeat = r.HeartbeatInterval
 		details.SessionID = r.SessionID
+		details.ResumeURL = r.ResumeGatewayURL
 	}).Invoke(payload.Data)
-	go w.events.Dispatch(payload.Event, payload.Data)
+
+	// The new session's sequence starts afresh.
+	atomic.StoreUint64(&w.lastSeq, payload.Sequence)
+	w.dispatcher.Push(payload.Event, payload.Data)
 
 	return details, err
 }
 
-// sendHandshake dispatches either an Identify or Resume packet on the
-// connection, depending whether we were connected before.
-func (w *Websocket) runHandshake(ws *websocket.Conn) (sessionDetails, error) {
-	sid := (*string)(atomic.LoadPointer(&w.sessionID))
+// readHello waits for the Hello packet the server sends immediately after
+// the connection is opened and returns the heartbeat interval it contains.
+func (w *Websocket) readHello(cnx *wsConn) (uint, error) {
+	payload, err := w.readPayload(cnx)
+	if err != nil {
+		return 0, err
+	}
+
+	if payload.Operation != Hello {
+		return 0, fmt.Errorf("cord/websocket: expected to get opcode %d, got %d",
+			Hello, payload.Operation)
+	}
+
+	hello := &model.Hello{}
+	if err := hello.UnmarshalJSON(payload.Data); err != nil {
+		return 0, err
+	}
+
+	if hello.HeartbeatInterval == 0 {
+		return 0, fmt.Errorf("cord/websocket: server sent an invalid heartbeat interval")
+	}
+
+	return hello.HeartbeatInterval, nil
+}
+
+// runHandshake waits for the server's Hello, then dispatches either an
+// Identify or Resume packet on the connection, depending whether we were
+// connected before.
+func (w *Websocket) runHandshake(cnx *wsConn) (details sessionDetails, err error) {
+	heartbeat, err := w.readHello(cnx)
+	if err != nil {
+		return details, err
+	}
 
+	sid := (*string)(atomic.LoadPointer(&w.sessionID))
 	if sid == nil {
-		return w.runHandshakeNew(ws)
+		w.setState(StateIdentifying)
+		details, err = w.runHandshakeNew(cnx)
 	} else {
-		return w.runHandshakeResume(ws, *sid)
+		w.setState(StateResuming)
+		details, err = w.runHandshakeResume(cnx, *sid)
 	}
+
+	details.Heartbeat = heartbeat
+	return details, err
 }
 
-// readPump reads off messages from the socket and dispatches them into the
-// handleIncoming method.
+// readPump reads off messages from the socket and passes them, in order,
+// to the handleIncoming method.
 func (w *Websocket) readPump(cnx *wsConn) {
+	defer close(cnx.readDone)
 	cnx.ws.SetReadDeadline(time.Time{})
 
 	for {
@@ -332,8 +757,20 @@ func (w *Websocket) readPump(cnx *wsConn) {
 
 		// Control frames won't have associated messages, only care about
 		// binary or text messages.
-		if kind == websocket.TextMessage || kind == websocket.BinaryMessage {
-			go w.handleIncoming(message, cnx)
+		if kind != websocket.TextMessage && kind != websocket.BinaryMessage {
+			continue
+		}
+
+		// Decompress here rather than in handleIncoming, since messages
+		// in a zlib stream must be inflated in order.
+		b, err := cnx.decompress(message)
+		if err != nil {
+			w.restart(fmt.Errorf("cord/websocket: error decompressing message: %s", err), cnx)
+			return
+		}
+
+		if b != nil && !w.handleIncoming(b, cnx) {
+			return
 		}
 	}
 }
@@ -344,33 +781,67 @@ func (w *Websocket) writeMessage(ws *websocket.Conn, data json.Marshaler) error
 		return err
 	}
 
-	ws.SetWriteDeadline(time.Now().Add(w.opts.Timeout))
 	w.opts.Debugger.Outgoing(bytes)
-	return ws.WriteMessage(websocket.TextMessage, bytes)
+
+	kind := websocket.TextMessage
+	if w.opts.Encoding != EncodingJSON {
+		kind = websocket.BinaryMessage
+		if bytes, err = w.opts.Encoding.encode(bytes); err != nil {
+			return err
+		}
+	}
+
+	ws.SetWriteDeadline(time.Now().Add(w.opts.Timeout))
+	return ws.WriteMessage(kind, bytes)
+}
+
+// sendHeartbeat writes a heartbeat with the last sequence number we saw.
+// Heartbeats are never held back by the rate limiter, but they do count
+// towards it.
+func (w *Websocket) sendHeartbeat(cnx *wsConn) error {
+	seq := atomic.LoadUint64(&w.lastSeq)
+	cnx.limiter.Force()
+	return w.writeMessage(cnx.ws, &Payload{
+		Operation: Heartbeat,
+		Data:      json.RawMessage(strconv.FormatUint(seq, 10)),
+	})
 }
 
 func (w *Websocket) writePump(cnx *wsConn, heartbeat time.Duration) {
 	ticker := time.NewTicker(heartbeat)
 	defer ticker.Stop()
 
+	// limited fires when the rate limit which is holding back queued
+	// messages resets. It's nil if we aren't being limited.
+	var limited <-chan time.Time
+
 	for {
 		var err error
 
 		select {
 		case <-ticker.C:
-			seq := atomic.LoadUint64(&w.lastSeq)
-			err = w.writeMessage(cnx.ws, &Payload{
-				Operation: Heartbeat,
-				Data:      json.RawMessage(strconv.FormatUint(seq, 10)),
-			})
-
-		case msg, ok := <-cnx.queue.Poll():
-			if !ok {
-				return
+			// If the server didn't acknowledge the previous heartbeat the
+			// connection is likely dead, even if TCP doesn't know it yet.
+			if !atomic.CompareAndSwapUint32(&cnx.acked, 1, 0) {
+				err = ErrHeartbeatTimeout
+				break
 			}
 
-			err = w.writeMessage(cnx.ws, msg.data)
-			msg.result <- err
+			err = w.sendHeartbeat(cnx)
+
+		case <-cnx.beat:
+			err = w.sendHeartbeat(cnx)
+
+		case <-cnx.queue.Done():
+			return
+
+		case <-cnx.queue.Ready():
+			// Even while limited, messages from TrySend may be sent
+			// right away.
+			limited, err = w.flushQueue(cnx)
+
+		case <-limited:
+			limited, err = w.flushQueue(cnx)
 		}
 
 		if err != nil {
@@ -380,15 +851,41 @@ func (w *Websocket) writePump(cnx *wsConn, heartbeat time.Duration) {
 	}
 }
 
-// unmarshalPayload parses and extracts the payload from the byte slice.
-func (w *Websocket) unmarshalPayload(b []byte) (*Payload, error) {
-	if len(b) > 0 && b[0] != '{' && b[0] != '[' {
-		var err error
-		if b, err = inflate(b); err != nil {
+// flushQueue writes queued messages until the queue is empty or the rate
+// limit is reached. In the latter case it returns a channel which fires
+// when the limit resets.
+func (w *Websocket) flushQueue(cnx *wsConn) (<-chan time.Time, error) {
+	allow := func(msg *queuedMessage) bool {
+		return msg.immediate || cnx.limiter.Allow(msg.data.Operation)
+	}
+
+	for {
+		msg := cnx.queue.Pop(allow)
+		if msg == nil {
+			break
+		}
+
+		err := w.writeMessage(cnx.ws, msg.data)
+		msg.result <- err
+		if err != nil {
 			return nil, err
 		}
 	}
 
+	if cnx.queue.Len() == 0 {
+		return nil, nil
+	}
+
+	return time.After(cnx.limiter.Delay()), nil
+}
+
+// unmarshalPayload parses and extracts the payload from the byte slice.
+func (w *Websocket) unmarshalPayload(b []byte) (*Payload, error) {
+	b, err := w.opts.Encoding.decode(b)
+	if err != nil {
+		return nil, err
+	}
+
 	w.opts.Debugger.Incoming(b)
 
 	wrapper := &Payload{}
@@ -402,45 +899,149 @@ func (w *Websocket) unmarshalPayload(b []byte) (*Payload, error) {
 // sendErr dispatches an error on the socket and notifies the debugger.
 func (w *Websocket) sendErr(err error) {
 	w.opts.Debugger.Error(err)
-	w.errs <- err
+	w.errs.Send(err)
 }
 
-// handleIncoming processes a message from the websocket and dispatches
-// it to clients.
-func (w *Websocket) handleIncoming(b []byte, cnx *wsConn) {
-	wrapper, err := w.unmarshalPayload(b)
+// resetSession forgets the current session, so that the next connection
+// identifies rather than resuming.
+func (w *Websocket) resetSession() {
+	atomic.StorePointer(&w.sessionID, unsafe.Pointer(nil))
+	atomic.StorePointer(&w.resumeURL, unsafe.Pointer(nil))
+	atomic.StoreUint64(&w.lastSeq, 0)
+	w.saveSession()
+}
+
+// session returns the current session, or nil if we don't have one.
+func (w *Websocket) session() *Session {
+	sid := (*string)(atomic.LoadPointer(&w.sessionID))
+	if sid == nil {
+		return nil
+	}
+
+	session := &Session{ID: *sid, Sequence: atomic.LoadUint64(&w.lastSeq)}
+	if u := (*string)(atomic.LoadPointer(&w.resumeURL)); u != nil {
+		session.ResumeURL = *u
+	}
+
+	return session
+}
+
+// saveSession queues the current session to be written to the
+// SessionStore, if any.
+func (w *Websocket) saveSession() {
+	if w.saver != nil {
+		w.saver.Save(w.session())
+	}
+}
+
+// loadSession restores the session from the SessionStore, if any, so that
+// the first connection resumes it.
+func (w *Websocket) loadSession() {
+	if w.opts.SessionStore == nil {
+		return
+	}
+
+	session, err := w.opts.SessionStore.Load()
 	if err != nil {
-		w.sendErr(fmt.Errorf("cord/websocket: error unpacking payload: %s", err))
+		go w.sendErr(fmt.Errorf("cord/websocket: error loading session: %s", err))
+		return
+	}
+	if session == nil || session.ID == "" {
 		return
 	}
 
+	atomic.StorePointer(&w.sessionID, unsafe.Pointer(&session.ID))
+	atomic.StoreUint64(&w.lastSeq, session.Sequence)
+	if session.ResumeURL != "" {
+		atomic.StorePointer(&w.resumeURL, unsafe.Pointer(&session.ResumeURL))
+	}
+}
+
+// handleIncoming processes a message from the websocket and queues events
+// for the dispatcher. It returns false if it restarted the connection.
+// Errors are sent asynchronously so that reading isn't held up until
+// someone reads them.
+func (w *Websocket) handleIncoming(b []byte, cnx *wsConn) bool {
+	wrapper, err := w.unmarshalPayload(b)
+	if err != nil {
+		go w.sendErr(fmt.Errorf("cord/websocket: error unpacking payload: %s", err))
+		return true
+	}
+
+	w.observe(wrapper)
 	switch wrapper.Operation {
 	case Dispatch:
-		atomic.StoreUint64(&w.lastSeq, wrapper.Sequence)
-		if err := w.events.Dispatch(wrapper.Event, wrapper.Data); err != nil {
-			w.sendErr(fmt.Errorf("cord/websocket: error dispatching event: %s", err))
+		w.receive(wrapper)
+	case Heartbeat:
+		select {
+		case cnx.beat <- struct{}{}:
+		default:
 		}
+	case HeartbeatAck:
+		atomic.StoreUint32(&cnx.acked, 1)
 	case Reconnect:
 		w.restart(nil, cnx)
+		return false
 	case InvalidSession:
-		atomic.StorePointer(&w.sessionID, unsafe.Pointer(nil))
+		w.resetSession()
 		w.restart(fmt.Errorf("cord/websocket: invalid session detected"), cnx)
+		return false
 	default:
-		w.sendErr(fmt.Errorf("cord/websocket: unhandled op code %d", wrapper.Operation))
+		if w.opts.OnOpcode == nil {
+			go w.sendErr(fmt.Errorf("cord/websocket: unhandled op code %d", wrapper.Operation))
+		}
+	}
+
+	return true
+}
+
+// observe passes the payload to the OnOpcode hook, if it isn't a Dispatch.
+func (w *Websocket) observe(payload *Payload) {
+	if w.opts.OnOpcode != nil && payload.Operation != Dispatch {
+		w.opts.OnOpcode(payload.Operation, payload.Data)
+	}
+}
+
+// receive records the dispatch's sequence number and queues it for the
+// handlers, unless it's a duplicate.
+func (w *Websocket) receive(payload *Payload) {
+	if !w.sequence(payload.Sequence) {
+		return
 	}
+
+	w.saveSession()
+	w.dispatcher.Push(payload.Event, payload.Data)
 }
 
 // On implements Socket.On
-func (w *Websocket) On(h events.Handler) { w.events.On(h) }
+func (w *Websocket) On(h events.Handler) Subscription {
+	w.warnIntents(h)
+	return w.events.On(h)
+}
 
 // Off implements Socket.Off
 func (w *Websocket) Off(h events.Handler) { w.events.Off(h) }
 
+// Use implements Socket.Use
+func (w *Websocket) Use(m Middleware) { w.events.Use(m) }
+
 // Once implements Socket.Once
-func (w *Websocket) Once(h events.Handler) { w.events.Once(h) }
+func (w *Websocket) Once(h events.Handler) Subscription {
+	w.warnIntents(h)
+	return w.events.Once(h)
+}
+
+// warnIntents sends an IntentWarning if the handler's event is excluded by
+// the intents in our handshake. The warning is sent asynchronously, as
+// handlers are usually attached before anyone is reading from Errs().
+func (w *Websocket) warnIntents(h events.Handler) {
+	if err := checkIntents(h, w.opts.Handshake.Intents); err != nil {
+		go w.sendErr(err)
+	}
+}
 
 // Errs implements Socket.Errs
-func (w *Websocket) Errs() <-chan error { return w.errs }
+func (w *Websocket) Errs() <-chan error { return w.errs.ch }
 
 // marshalPayload marshals the provided data for transport over the socket.
 func (w *Websocket) marshalPayload(op Operation, data json.Marshaler) (*Payload, error) {
@@ -457,33 +1058,204 @@ func (w *Websocket) marshalPayload(op Operation, data json.Marshaler) (*Payload,
 
 // Send implements Socket.Send
 func (w *Websocket) Send(op Operation, data json.Marshaler) error {
+	return w.SendContext(context.Background(), op, data)
+}
+
+// SendContext implements Socket.SendContext
+func (w *Websocket) SendContext(ctx context.Context, op Operation, data json.Marshaler) error {
+	payload, err := w.marshalPayload(op, data)
+	if err != nil {
+		return err
+	}
+
+	cnx := (*wsConn)(atomic.LoadPointer(&w.ws))
+	if cnx == nil {
+		return ErrClosed
+	}
+
+	return w.sendQueued(ctx, cnx, payload)
+}
+
+// TrySend implements Socket.TrySend
+func (w *Websocket) TrySend(op Operation, data json.Marshaler) error {
 	payload, err := w.marshalPayload(op, data)
 	if err != nil {
 		return err
 	}
 
-	result := make(chan error, 1)
 	cnx := (*wsConn)(atomic.LoadPointer(&w.ws))
-	cnx.queue.Push(&queuedMessage{payload, result})
-	return <-result
+	if cnx == nil {
+		return ErrClosed
+	}
+	if state := w.State(); state != StateConnected || cnx.limiter == nil {
+		return NotConnectedError{state}
+	}
+	if !cnx.limiter.Allow(op) {
+		return RateLimitedError{time.Now().Add(cnx.limiter.Delay())}
+	}
+
+	msg := newQueuedMessage(payload)
+	msg.immediate = true
+	cnx.queue.Push(context.Background(), msg)
+
+	return <-msg.result
+}
+
+// sendQueued pushes the payload onto the connection's queue and waits for
+// it to be sent. If the context is done first, the payload is removed from
+// the queue, unless it's already being written.
+func (w *Websocket) sendQueued(ctx context.Context, cnx *wsConn, payload *Payload) error {
+	msg := newQueuedMessage(payload)
+	cnx.queue.Push(ctx, msg)
+
+	select {
+	case err := <-msg.result:
+		return err
+	case <-ctx.Done():
+		if !msg.claim() {
+			return <-msg.result
+		}
+
+		cnx.queue.Remove(msg)
+		return ctx.Err()
+	}
+}
+
+// State implements Socket.State
+func (w *Websocket) State() State { return State(atomic.LoadUint32(&w.state)) }
+
+// OnStateChange implements Socket.OnStateChange
+func (w *Websocket) OnStateChange(h func(old, new State)) { w.states.On(h) }
+
+// Budget returns how many more commands may be sent on the current
+// connection before the gateway's rate limits are reached.
+func (w *Websocket) Budget() Budget {
+	cnx := (*wsConn)(atomic.LoadPointer(&w.ws))
+	if cnx == nil {
+		return fullBudget()
+	}
+
+	budget := fullBudget()
+	if cnx.limiter != nil {
+		budget = cnx.limiter.Budget()
+	}
+	budget.Queued = cnx.queue.Len()
+
+	return budget
+}
+
+// Shutdown implements Socket.Shutdown
+func (w *Websocket) Shutdown(ctx context.Context) error {
+	err := w.shutdown(ctx)
+	w.errs.Close()
+	return err
 }
 
-// Close frees resources associated with the websocket.
+// shutdown is Shutdown, without closing the errs channel, which the
+// ShardManager shares between shards.
+func (w *Websocket) shutdown(ctx context.Context) error {
+	defer w.saver.Close()
+
+	if err := w.flush(ctx); err != nil {
+		w.close()
+		return err
+	}
+
+	cnx := w.detach()
+	if cnx == nil {
+		return nil
+	}
+	defer cnx.Close()
+	cnx.queue.Fail(ErrClosed)
+
+	code := websocket.CloseNormalClosure
+	if w.opts.ResumeAfterShutdown {
+		code = int(CloseUnknownError)
+	} else {
+		w.resetSession()
+	}
+
+	if cnx.ws == nil {
+		return nil
+	}
+
+	deadline := time.Now().Add(w.opts.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	msg := websocket.FormatCloseMessage(code, "")
+	if err := cnx.ws.WriteControl(websocket.CloseMessage, msg, deadline); err != nil {
+		return err
+	}
+
+	select {
+	case <-cnx.readDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flush waits until every queued message has been sent, following the
+// queue through reconnects.
+func (w *Websocket) flush(ctx context.Context) error {
+	for {
+		cnx := (*wsConn)(atomic.LoadPointer(&w.ws))
+		if cnx == nil {
+			return nil
+		}
+
+		select {
+		case <-cnx.queue.Drained():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		// If the queue was moved to a new connection, wait on that.
+		if next := (*wsConn)(atomic.LoadPointer(&w.ws)); next == nil || next.queue == cnx.queue {
+			return nil
+		}
+	}
+}
+
+// Close implements Socket.Close
 func (w *Websocket) Close() error {
-	cnx := (*wsConn)(atomic.SwapPointer(&w.ws, unsafe.Pointer(nil)))
+	err := w.close()
+	w.errs.Close()
+	return err
+}
+
+// close is Close, without closing the errs channel.
+func (w *Websocket) close() error {
+	defer w.saver.Close()
+
+	cnx := w.detach()
 	if cnx == nil {
 		return nil
 	}
 
+	cnx.queue.Fail(ErrClosed)
 	return cnx.Close()
 }
 
-// inflate decompresses the provided zlib-compressed bytes
-func inflate(b []byte) ([]byte, error) {
-	r, err := zlib.NewReader(bytes.NewReader(b))
-	if err != nil {
-		return nil, err
+// detach moves to StateClosed and returns the current connection, if any,
+// which the caller is responsible for closing. It interrupts any handshake
+// or wait to reconnect, so that the goroutines we started exit.
+func (w *Websocket) detach() *wsConn {
+	w.mu.Lock()
+	cnx := (*wsConn)(atomic.SwapPointer(&w.ws, unsafe.Pointer(nil)))
+	w.storeState(StateClosed)
+	dialing := w.dialing
+	w.dialing = nil
+	w.mu.Unlock()
+
+	w.states.Flush()
+	w.doneOnce.Do(func() { close(w.done) })
+	if dialing != nil {
+		dialing.Close()
 	}
+	w.streams.Close()
 
-	return ioutil.ReadAll(r)
+	return cnx
 }
@@ -3,19 +3,35 @@ package cord
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unsafe"
 
+	"github.com/WatchBeam/cord/etf"
 	"github.com/WatchBeam/cord/events"
 	"github.com/WatchBeam/cord/model"
+	"github.com/cenk/backoff"
 	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
 var (
+	helloPacket = []byte(`{
+        "op":10,
+        "d": {"heartbeat_interval": 10000}
+    }`)
+
 	readyPacket = []byte(`{
         "op":0,
         "t": "READY",
@@ -25,7 +41,7 @@ var (
 
 	resumedPacket = []byte(`{
         "t":"RESUMED",
-        "s":3,
+        "s":2,
         "op":0,
         "d":{"heartbeat_interval":41250}
     }`)
@@ -45,25 +61,37 @@ type testGatewayRetriever struct{ gateway string }
 func (t testGatewayRetriever) Gateway() (string, error) { return t.gateway, nil }
 
 func (w *WebsocketSuite) SetupTest() {
+	// Capture the channel so that sockets left over from previous tests
+	// can't steal handlers meant for this one.
+	onConnect := make(chan func(c *websocket.Conn), 16)
 	w.ts = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
 		if err != nil {
 			panic(err)
 		}
-		(<-w.onConnect)(c)
+		(<-onConnect)(c)
 	}))
 
 	w.closer = make(chan struct{})
-	w.onConnect = make(chan func(c *websocket.Conn), 16)
+	w.onConnect = onConnect
 	w.socket = New("tooken", &WsOptions{
 		Gateway: testGatewayRetriever{strings.Replace(w.ts.URL, "http://", "ws://", 1)},
 	}).(*Websocket)
 }
 
+// sendHello writes the initial Hello packet every gateway connection
+// starts with.
+func sendHello(c *websocket.Conn) {
+	c.WriteMessage(websocket.TextMessage, helloPacket)
+}
+
 func (w *WebsocketSuite) panicOnError() {
 	for {
 		select {
-		case err := <-w.socket.Errs():
+		case err, ok := <-w.socket.Errs():
+			if !ok {
+				return
+			}
 			panic(err)
 		case <-w.closer:
 			return
@@ -71,7 +99,7 @@ func (w *WebsocketSuite) panicOnError() {
 	}
 }
 
-func (w *WebsocketSuite) TeardownTest() {
+func (w *WebsocketSuite) TearDownTest() {
 	close(w.closer)
 	w.socket.Close()
 	w.ts.Close()
@@ -79,6 +107,7 @@ func (w *WebsocketSuite) TeardownTest() {
 
 func (w *WebsocketSuite) TestHandshakesAndReconnectsCorrectly() {
 	w.onConnect <- func(c *websocket.Conn) {
+		sendHello(c)
 		_, msg, err := c.ReadMessage()
 		w.Nil(err)
 		w.Equal(`{"op":2,"d":{"token":"tooken","properties":{"$os":"`+
@@ -90,10 +119,11 @@ func (w *WebsocketSuite) TestHandshakesAndReconnectsCorrectly() {
 	}
 
 	w.onConnect <- func(c *websocket.Conn) {
+		sendHello(c)
 		_, msg, err := c.ReadMessage()
 		w.Nil(err)
 		w.Equal(`{"op":6,"d":{"token":"tooken","session_id":"asdf",`+
-			`"seq":0},"s":0,"t":""}`, string(msg))
+			`"seq":1},"s":0,"t":""}`, string(msg))
 		c.WriteMessage(websocket.TextMessage, resumedPacket)
 		c.Close()
 	}
@@ -112,18 +142,113 @@ func (w *WebsocketSuite) TestHandshakesAndReconnectsCorrectly() {
 	<-done
 }
 
+func (w *WebsocketSuite) TestReportsStateChanges() {
+	var mu sync.Mutex
+	var transitions []string
+	w.socket.OnStateChange(func(old, new State) {
+		mu.Lock()
+		transitions = append(transitions, old.String()+" -> "+new.String())
+		mu.Unlock()
+	})
+
+	w.onConnect <- func(c *websocket.Conn) {
+		sendHello(c)
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+		c.Close()
+	}
+
+	w.onConnect <- func(c *websocket.Conn) {
+		sendHello(c)
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, resumedPacket)
+	}
+
+	done := make(chan struct{})
+	w.socket.Once(events.Ready(func(r *model.Ready) {
+		<-w.socket.Errs()
+		w.socket.Once(events.Resumed(func(r *model.Resumed) { close(done) }))
+	}))
+	<-done
+
+	w.Eventually(func() bool { return w.socket.State() == StateConnected }, time.Second, time.Millisecond)
+	w.socket.Close()
+	w.Equal(StateClosed, w.socket.State())
+
+	mu.Lock()
+	defer mu.Unlock()
+	w.Equal([]string{
+		"connecting -> identifying",
+		"identifying -> connected",
+		"connected -> connecting",
+		"connecting -> resuming",
+		"resuming -> connected",
+		"connected -> closed",
+	}, transitions)
+}
+
 func (w *WebsocketSuite) TestLogsInvalidTokenAsFatalError() {
 	w.onConnect <- func(c *websocket.Conn) {
+		sendHello(c)
 		c.ReadMessage()
 		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4004, "Authentication"))
 		c.Close()
 	}
 
-	w.IsType(FatalError{}, <-w.socket.Errs())
+	err := <-w.socket.Errs()
+	w.IsType(FatalError{}, err)
+	w.Equal(CloseError{CloseAuthenticationFailed, "Authentication"}, err.(FatalError).Cause)
+	w.Equal(StateClosed, w.socket.State())
+}
+
+func (w *WebsocketSuite) TestStopsOnFatalCloseCode() {
+	w.onConnect <- func(c *websocket.Conn) {
+		sendHello(c)
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4014, "Disallowed intent(s)."))
+		c.Close()
+	}
+
+	err := <-w.socket.Errs()
+	w.IsType(FatalError{}, err)
+	w.Equal(CloseDisallowedIntents, err.(FatalError).Cause.(CloseError).Code)
+}
+
+func (w *WebsocketSuite) TestReidentifiesOnSessionClosingCode() {
+	w.onConnect <- func(c *websocket.Conn) {
+		sendHello(c)
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+		c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4009, "Session timed out."))
+		c.Close()
+	}
+
+	w.onConnect <- func(c *websocket.Conn) {
+		sendHello(c)
+		_, msg, err := c.ReadMessage()
+		w.Nil(err)
+		w.Contains(string(msg), `"op":2`)
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+	}
+
+	done := make(chan struct{})
+	w.socket.Once(events.Ready(func(r *model.Ready) {
+		err := <-w.socket.Errs()
+		w.IsType(DisruptionError{}, err)
+		w.Equal(CloseSessionTimedOut, err.(DisruptionError).Cause.(CloseError).Code)
+
+		w.socket.Once(events.Ready(func(r *model.Ready) {
+			close(done)
+		}))
+	}))
+
+	<-done
 }
 
 func (w *WebsocketSuite) TestRetriesTokenOnInvalidSession() {
 	w.onConnect <- func(c *websocket.Conn) {
+		sendHello(c)
 		// initially it'll send the token
 		_, msg, err := c.ReadMessage()
 		w.Nil(err)
@@ -135,6 +260,7 @@ func (w *WebsocketSuite) TestRetriesTokenOnInvalidSession() {
 	w.onConnect <- func(c *websocket.Conn) {
 		// when we restart we won't respond with a ready and close the socket
 		// like Discord does.
+		sendHello(c)
 		_, msg, err := c.ReadMessage()
 		w.Contains(string(msg), `"op":6`)
 		w.Nil(err)
@@ -160,8 +286,49 @@ func (w *WebsocketSuite) TestRetriesTokenOnInvalidSession() {
 	<-done
 }
 
+func (w *WebsocketSuite) TestTrySendDoesntWaitForRateLimits() {
+	received := make(chan string, 1)
+	w.onConnect <- func(c *websocket.Conn) {
+		sendHello(c)
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+		for {
+			_, msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}
+
+	w.Eventually(func() bool { return w.socket.State() == StateConnected }, time.Second, time.Millisecond)
+	cnx := (*wsConn)(atomic.LoadPointer(&w.socket.ws))
+
+	// A status update held back by its own limit doesn't hold up others.
+	cnx.limiter.mu.Lock()
+	cnx.limiter.status.tokens = 0
+	cnx.limiter.status.reset = time.Now().Add(time.Minute)
+	cnx.limiter.mu.Unlock()
+	go w.socket.Send(StatusUpdate, json.RawMessage(`{}`))
+	w.Eventually(func() bool { return w.socket.Budget().Queued == 1 }, time.Second, time.Millisecond)
+
+	w.Nil(w.socket.TrySend(RequestMembers, json.RawMessage(`{}`)))
+	w.Contains(<-received, `"op":8`)
+
+	cnx.limiter.mu.Lock()
+	cnx.limiter.commands.tokens = heartbeatReserve
+	cnx.limiter.commands.reset = time.Now().Add(time.Minute)
+	cnx.limiter.mu.Unlock()
+
+	err := w.socket.TrySend(RequestMembers, json.RawMessage(`{}`))
+	if w.IsType(RateLimitedError{}, err) {
+		w.WithinDuration(time.Now().Add(time.Minute), err.(RateLimitedError).Reset, time.Second)
+	}
+}
+
 func (w *WebsocketSuite) TestReadsGzippedData() {
 	w.onConnect <- func(c *websocket.Conn) {
+		sendHello(c)
 		_, _, err := c.ReadMessage()
 		w.Nil(err)
 
@@ -181,6 +348,648 @@ func (w *WebsocketSuite) TestReadsGzippedData() {
 	<-done
 }
 
+func (w *WebsocketSuite) TestRestartsWhenHeartbeatIsNotAcked() {
+	w.onConnect <- func(c *websocket.Conn) {
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":10,"d":{"heartbeat_interval":50}}`))
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+
+		// Read, but never acknowledge, heartbeats.
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}
+
+	err := <-w.socket.Errs()
+	w.IsType(DisruptionError{}, err)
+	w.Equal(ErrHeartbeatTimeout, err.(DisruptionError).Cause)
+}
+
+func (w *WebsocketSuite) TestKeepsConnectionWhenHeartbeatIsAcked() {
+	w.onConnect <- func(c *websocket.Conn) {
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":10,"d":{"heartbeat_interval":20}}`))
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+
+		for i := 0; i < 5; i++ {
+			_, msg, err := c.ReadMessage()
+			w.Nil(err)
+			w.Contains(string(msg), `"op":1`)
+			c.WriteMessage(websocket.TextMessage, []byte(`{"op":11}`))
+		}
+
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"RESUMED","s":2,"d":{}}`))
+	}
+
+	done := make(chan struct{})
+	w.socket.Once(events.Resumed(func(r *model.Resumed) { close(done) }))
+
+	select {
+	case err := <-w.socket.Errs():
+		w.Fail("unexpected error", err.Error())
+	case <-done:
+	}
+}
+
+func TestReadsZlibStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "zlib-stream", r.URL.Query().Get("compress"))
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		zw := newZlibStreamWriter()
+		c.WriteMessage(websocket.BinaryMessage, zw.Payload(helloPacket))
+		_, msg, err := c.ReadMessage()
+		assert.Nil(t, err)
+		assert.Contains(t, string(msg), `"compress":false`)
+
+		ready := zw.Payload(readyPacket)
+		c.WriteMessage(websocket.BinaryMessage, ready[:len(ready)/2])
+		c.WriteMessage(websocket.BinaryMessage, ready[len(ready)/2:])
+		c.WriteMessage(websocket.BinaryMessage, zw.Payload(resumedPacket))
+		c.ReadMessage()
+	}))
+	defer ts.Close()
+
+	socket := New("tooken", &WsOptions{
+		Gateway:     testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+		Compression: CompressZlibStream,
+	})
+	defer socket.Close()
+
+	ready, resumed := make(chan struct{}), make(chan struct{})
+	socket.Once(events.Ready(func(r *model.Ready) { close(ready) }))
+	socket.Once(events.Resumed(func(r *model.Resumed) { close(resumed) }))
+	<-ready
+	<-resumed
+}
+
+func TestSpeaksETF(t *testing.T) {
+	etfPayload := func(b []byte) []byte {
+		out, err := etf.FromJSON(b)
+		if err != nil {
+			panic(err)
+		}
+		return out
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "etf", r.URL.Query().Get("encoding"))
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		c.WriteMessage(websocket.BinaryMessage, etfPayload(helloPacket))
+		kind, msg, err := c.ReadMessage()
+		assert.Nil(t, err)
+		assert.Equal(t, websocket.BinaryMessage, kind)
+		handshake, err := etf.ToJSON(msg)
+		assert.Nil(t, err)
+		assert.Contains(t, string(handshake), `"token":"tooken"`)
+
+		c.WriteMessage(websocket.BinaryMessage, etfPayload(readyPacket))
+		c.ReadMessage()
+	}))
+	defer ts.Close()
+
+	socket := New("tooken", &WsOptions{
+		Gateway:  testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+		Encoding: EncodingETF,
+	})
+	defer socket.Close()
+
+	done := make(chan struct{})
+	socket.Once(events.Ready(func(r *model.Ready) {
+		assert.Equal(t, "asdf", r.SessionID)
+		close(done)
+	}))
+	<-done
+}
+
+// memorySessionStore is a SessionStore which records every save.
+type memorySessionStore struct {
+	mu    sync.Mutex
+	saves []*Session
+}
+
+func (m *memorySessionStore) Load() (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.saves) == 0 {
+		return nil, nil
+	}
+	return m.saves[len(m.saves)-1], nil
+}
+
+func (m *memorySessionStore) Save(s *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.saves = append(m.saves, s)
+	return nil
+}
+
+func TestResumesStoredSession(t *testing.T) {
+	resumed := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/resume", r.URL.Path)
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		sendHello(c)
+		_, msg, err := c.ReadMessage()
+		assert.Nil(t, err)
+		assert.Equal(t, `{"op":6,"d":{"token":"tooken","session_id":"stored",`+
+			`"seq":41},"s":0,"t":""}`, string(msg))
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"RESUMED","s":42,"d":{}}`))
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"TYPING_START","s":43,"d":{}}`))
+		<-resumed
+	}))
+	defer ts.Close()
+	defer close(resumed)
+
+	gateway := strings.Replace(ts.URL, "http://", "ws://", 1)
+	store := &memorySessionStore{}
+	store.Save(&Session{ID: "stored", Sequence: 41, ResumeURL: gateway + "/resume"})
+
+	socket := New("tooken", &WsOptions{
+		Gateway:      testGatewayRetriever{gateway},
+		SessionStore: store,
+	})
+	defer socket.Close()
+
+	done := make(chan struct{})
+	socket.Once(events.Resumed(func(r *model.Resumed) { close(done) }))
+	<-done
+
+	assert.Eventually(t, func() bool {
+		session, _ := store.Load()
+		return session.Sequence == 43
+	}, time.Second, time.Millisecond)
+
+	session, _ := store.Load()
+	assert.Equal(t, &Session{ID: "stored", Sequence: 43, ResumeURL: gateway + "/resume"}, session)
+}
+
+func TestResumeDropsReplayedDuplicates(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		sendHello(c)
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"MESSAGE_CREATE","s":41,"d":{"id":"41"}}`))
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"MESSAGE_CREATE","s":42,"d":{"id":"42"}}`))
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"RESUMED","s":43,"d":{}}`))
+		c.ReadMessage()
+	}))
+	defer ts.Close()
+
+	store := &memorySessionStore{}
+	store.Save(&Session{ID: "stored", Sequence: 41})
+	socket := New("tooken", &WsOptions{
+		Gateway:      testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+		SessionStore: store,
+	})
+	defer socket.Close()
+
+	var got []string
+	done := make(chan struct{})
+	socket.On(events.MessageCreate(func(m *model.Message) { got = append(got, m.ID) }))
+	socket.Once(events.Resumed(func(r *model.Resumed) { close(done) }))
+	<-done
+
+	assert.Equal(t, []string{"42"}, got)
+	assert.Equal(t, SequenceStats{Duplicates: 1}, socket.SequenceStats())
+}
+
+// countingGatewayRetriever counts how often the gateway is looked up.
+type countingGatewayRetriever struct {
+	gateway string
+	calls   int32
+}
+
+func (c *countingGatewayRetriever) Gateway() (string, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return c.gateway, nil
+}
+
+func TestResumesOnResumeGatewayURL(t *testing.T) {
+	var gateway string
+	connections := make(chan *http.Request, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		connections <- r
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		sendHello(c)
+		_, msg, err := c.ReadMessage()
+		assert.Nil(t, err)
+
+		if r.URL.Path == "/resume" {
+			assert.Contains(t, string(msg), `"op":6`)
+			c.WriteMessage(websocket.TextMessage, resumedPacket)
+			c.ReadMessage()
+			return
+		}
+
+		assert.Contains(t, string(msg), `"op":2`)
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"READY","s":1,`+
+			`"d":{"session_id":"asdf","resume_gateway_url":"`+gateway+`/resume"}}`))
+	}))
+	defer ts.Close()
+
+	gateway = strings.Replace(ts.URL, "http://", "ws://", 1)
+	retriever := &countingGatewayRetriever{gateway: gateway}
+	socket := New("tooken", &WsOptions{Gateway: retriever})
+	defer socket.Close()
+
+	done := make(chan struct{})
+	socket.Once(events.Resumed(func(r *model.Resumed) { close(done) }))
+	go func() {
+		for range socket.Errs() {
+		}
+	}()
+	<-done
+
+	assert.Equal(t, "/", (<-connections).URL.Path)
+	assert.Equal(t, "/resume", (<-connections).URL.Path)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&retriever.calls))
+}
+
+// newDisconnectedWebsocket returns a Websocket which is waiting to
+// reconnect.
+func newDisconnectedWebsocket() *Websocket {
+	ws := newWebsocket("tooken", &WsOptions{
+		Gateway: testGatewayRetriever{"ws://127.0.0.1:0"},
+	}, newEmitter(), newErrChannel())
+	ws.ws = unsafe.Pointer(ws.newPendingConn())
+	return ws
+}
+
+func TestSendContextRemovesCancelledMessages(t *testing.T) {
+	ws := newDisconnectedWebsocket()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := ws.SendContext(ctx, StatusUpdate, json.RawMessage(`{}`))
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 0, ws.Budget().Queued)
+}
+
+func TestTrySendFailsWhenNotConnected(t *testing.T) {
+	ws := newDisconnectedWebsocket()
+
+	err := ws.TrySend(StatusUpdate, json.RawMessage(`{}`))
+	assert.Equal(t, NotConnectedError{StateConnecting}, err)
+	assert.Equal(t, 0, ws.Budget().Queued)
+}
+
+func TestCloseFailsQueuedSends(t *testing.T) {
+	ws := newDisconnectedWebsocket()
+
+	result := make(chan error)
+	go func() { result <- ws.Send(StatusUpdate, json.RawMessage(`{}`)) }()
+	assert.Eventually(t, func() bool { return ws.Budget().Queued == 1 }, time.Second, time.Millisecond)
+
+	ws.Close()
+	assert.Equal(t, ErrClosed, <-result)
+	assert.Equal(t, ErrClosed, ws.Send(StatusUpdate, json.RawMessage(`{}`)))
+}
+
+func TestShutdownFlushesAndClosesGracefully(t *testing.T) {
+	for _, resume := range []bool{false, true} {
+		closed := make(chan int, 1)
+		proceed := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+			if err != nil {
+				panic(err)
+			}
+			defer c.Close()
+
+			<-proceed
+			sendHello(c)
+			c.ReadMessage()
+			c.WriteMessage(websocket.TextMessage, readyPacket)
+
+			_, msg, err := c.ReadMessage()
+			assert.Nil(t, err)
+			assert.Contains(t, string(msg), `"op":3`)
+
+			_, _, err = c.ReadMessage()
+			if cerr, ok := err.(*websocket.CloseError); ok {
+				closed <- cerr.Code
+			}
+		}))
+
+		store := &memorySessionStore{}
+		socket := New("tooken", &WsOptions{
+			Gateway:             testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+			SessionStore:        store,
+			ResumeAfterShutdown: resume,
+		})
+
+		// Queue a send before the socket connects; Shutdown should wait
+		// for it to be sent.
+		sent := make(chan error)
+		go func() { sent <- socket.Send(StatusUpdate, json.RawMessage(`{}`)) }()
+		assert.Eventually(t, func() bool { return socket.Budget().Queued == 1 }, time.Second, time.Millisecond)
+
+		shutdown := make(chan error)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			shutdown <- socket.Shutdown(ctx)
+		}()
+
+		close(proceed)
+		assert.Nil(t, <-sent)
+		assert.Nil(t, <-shutdown)
+
+		session, _ := store.Load()
+		if resume {
+			assert.Equal(t, int(CloseUnknownError), <-closed)
+			assert.Equal(t, "asdf", session.ID)
+		} else {
+			assert.Equal(t, websocket.CloseNormalClosure, <-closed)
+			assert.Nil(t, session)
+		}
+
+		_, ok := <-socket.Errs()
+		assert.False(t, ok)
+		assert.Equal(t, StateClosed, socket.State())
+		ts.Close()
+	}
+}
+
+func TestDispatchesEventsInOrder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		sendHello(c)
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+		for i := 0; i < 50; i++ {
+			c.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(
+				`{"op":0,"t":"MESSAGE_CREATE","s":%d,"d":{"id":"%d"}}`, i+2, i)))
+		}
+		c.ReadMessage()
+	}))
+	defer ts.Close()
+
+	socket := New("tooken", &WsOptions{
+		Gateway: testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+	}).(*Websocket)
+	defer socket.Close()
+
+	var got []string
+	done := make(chan struct{})
+	socket.On(events.Ready(func(r *model.Ready) { got = append(got, "ready") }))
+	socket.On(events.MessageCreate(func(m *model.Message) {
+		// Make later events likely to overtake this one if they could.
+		time.Sleep(time.Millisecond)
+		got = append(got, m.ID)
+		if len(got) == 51 {
+			close(done)
+		}
+	}))
+	<-done
+
+	want := []string{"ready"}
+	for i := 0; i < 50; i++ {
+		want = append(want, strconv.Itoa(i))
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, uint64(51), atomic.LoadUint64(&socket.lastSeq))
+}
+
+func TestRecoversHandlerPanics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		sendHello(c)
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"MESSAGE_CREATE","s":2,"d":{"id":"1"}}`))
+		c.ReadMessage()
+	}))
+	defer ts.Close()
+
+	socket := New("tooken", &WsOptions{
+		Gateway: testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+	})
+	defer socket.Close()
+
+	done := make(chan struct{})
+	socket.On(events.Ready(func(r *model.Ready) { panic("oh no") }))
+	socket.On(events.MessageCreate(func(m *model.Message) { close(done) }))
+
+	err := <-socket.Errs()
+	assert.IsType(t, HandlerPanicError{}, err)
+	assert.Equal(t, events.ReadyStr, err.(HandlerPanicError).Event)
+	<-done
+}
+
+func TestRecoversMiddlewarePanics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		sendHello(c)
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":0,"t":"MESSAGE_CREATE","s":2,"d":{"id":"1"}}`))
+		c.ReadMessage()
+	}))
+	defer ts.Close()
+
+	socket := New("tooken", &WsOptions{
+		Gateway: testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+	})
+	defer socket.Close()
+
+	done := make(chan struct{})
+	socket.Use(func(event string, data []byte, next DispatchFunc) error {
+		if event == events.ReadyStr {
+			panic("oh no")
+		}
+		return next(event, data)
+	})
+	socket.On(events.MessageCreate(func(m *model.Message) { close(done) }))
+
+	err := <-socket.Errs()
+	assert.IsType(t, MiddlewarePanicError{}, err)
+	assert.Equal(t, events.ReadyStr, err.(MiddlewarePanicError).Event)
+	<-done
+}
+
+func TestOnOpcodeSeesNonDispatchPayloads(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		sendHello(c)
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":42,"d":{"new":true}}`))
+		c.ReadMessage()
+	}))
+	defer ts.Close()
+
+	ops := make(chan string, 2)
+	socket := New("tooken", &WsOptions{
+		Gateway: testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+		OnOpcode: func(op Operation, data []byte) {
+			ops <- fmt.Sprintf("%d %s", op, data)
+		},
+	})
+	defer socket.Close()
+
+	assert.Equal(t, `10 {"heartbeat_interval": 10000}`, <-ops)
+	assert.Equal(t, `42 {"new":true}`, <-ops)
+
+	select {
+	case err := <-socket.Errs():
+		t.Errorf("expected the opcode not to be reported, got %s", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// cordGoroutines returns the IDs of running goroutines which were started
+// by the package, other than by tests.
+func cordGoroutines() map[string]bool {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	ids := map[string]bool{}
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		i := strings.Index(stack, "created by github.com/WatchBeam/cord.")
+		if i == -1 {
+			continue
+		}
+		if creator := stack[i:]; strings.Contains(creator, ".Test") || strings.Contains(creator, "Suite") {
+			continue
+		}
+
+		ids[strings.Fields(stack)[1]] = true
+	}
+
+	return ids
+}
+
+// checkLeaks asserts that every goroutine the package starts after it's
+// called has exited by the time the returned function is called.
+func checkLeaks(t *testing.T) func() {
+	before := cordGoroutines()
+	return func() {
+		leaked := func() bool {
+			for id := range cordGoroutines() {
+				if !before[id] {
+					return true
+				}
+			}
+			return false
+		}
+
+		assert.Eventually(t, func() bool { return !leaked() }, time.Second, 10*time.Millisecond,
+			"goroutines were leaked")
+	}
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	defer checkLeaks(t)()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		defer c.Close()
+
+		sendHello(c)
+		c.ReadMessage()
+		c.WriteMessage(websocket.TextMessage, readyPacket)
+		c.WriteMessage(websocket.TextMessage, []byte(`{"op":42}`))
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+
+	socket := New("tooken", &WsOptions{
+		Gateway: testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+		Backoff: backoff.NewConstantBackOff(time.Hour),
+	})
+
+	// The socket is closed while connected, with the unknown op code's
+	// error waiting for someone to read it.
+	ready := make(chan struct{})
+	socket.Once(events.Ready(func(r *model.Ready) { close(ready) }))
+	<-ready
+
+	assert.Nil(t, socket.Close())
+	_, ok := <-socket.Errs()
+	assert.False(t, ok)
+	assert.Equal(t, ErrClosed, socket.Send(StatusUpdate, json.RawMessage(`{}`)))
+}
+
+func TestCloseInterruptsReconnecting(t *testing.T) {
+	defer checkLeaks(t)()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		c, err := (&websocket.Upgrader{}).Upgrade(rw, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		c.Close()
+	}))
+	defer ts.Close()
+
+	socket := New("tooken", &WsOptions{
+		Gateway: testGatewayRetriever{strings.Replace(ts.URL, "http://", "ws://", 1)},
+		Backoff: backoff.NewConstantBackOff(time.Hour),
+	})
+
+	assert.IsType(t, DisruptionError{}, <-socket.Errs())
+	assert.Nil(t, socket.Close())
+	_, ok := <-socket.Errs()
+	assert.False(t, ok)
+}
+
 func TestWebsocketSuite(t *testing.T) {
 	suite.Run(t, new(WebsocketSuite))
 }